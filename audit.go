@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/golang-ui/nuklear/nk"
+	"github.com/michalnicp/1pass/audit"
+	"github.com/michalnicp/1pass/logging"
+	"github.com/pkg/errors"
+)
+
+// runAudit writes a json report of weak, reused and old passwords.
+func runAudit(args []string) error {
+	opts := audit.DefaultOptions
+
+	flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+	maxAge := flags.Int("max-age", int(opts.MaxAge/(24*time.Hour)), "age in days after which a password is old, 0 to disable")
+	flags.IntVar(&opts.MinScore, "min-score", opts.MinScore, "minimum strength score from 0 to 4 of a password that isn't weak")
+	flags.DurationVar(&opts.Interval, "interval", opts.Interval, "minimum time between fetching items")
+	hibp := flags.String("hibp", "", "Have I Been Pwned password file ordered by hash to check passwords against")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	opts.MaxAge = time.Duration(*maxAge) * 24 * time.Hour
+
+	if *hibp != "" {
+		db, err := audit.OpenBreachDB(*hibp)
+		if err != nil {
+			return errors.Wrap(err, "open breach file")
+		}
+		defer db.Close()
+		opts.BreachDB = db
+	}
+
+	session, err := cliSession()
+	if err != nil {
+		return err
+	}
+
+	report, err := audit.Run(session, opts)
+	if err != nil {
+		return errors.Wrap(err, "audit")
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// startAudit audits the passwords in the background.
+func startAudit(state *UIState) {
+	if state.isAuditing {
+		return
+	}
+
+	state.isAuditing = true
+	state.statusText = "auditing passwords"
+
+	session := state.session
+	go func() {
+		defer state.queue(func() {
+			state.isAuditing = false
+		})
+
+		report, err := audit.Run(session, state.auditOptions)
+		if err != nil {
+			logging.Error("audit", "err", err)
+			state.queue(func() {
+				state.statusText = fmt.Sprintf("audit: %v", err)
+			})
+			return
+		}
+
+		state.queue(func() {
+			state.auditReport = report
+			state.statusText = fmt.Sprintf("%d weak, %d reused, %d old, %d breached of %d passwords",
+				report.Weak, report.Reused, report.Old, report.Breached, report.Audited)
+		})
+	}()
+}
+
+// Audit draws the password audit report.
+func Audit(window *glfw.Window, ctx *nk.Context, state *UIState) {
+	if state.auditReport == nil {
+		startAudit(state)
+	}
+
+	width, height := window.GetSize()
+	bounds := nk.NkRect(0, 0, float32(width), float32(height))
+	if nk.NkBegin(ctx, "audit", bounds, nk.WindowNoScrollbar) > 0 {
+		region := nk.NkWindowGetContentRegion(ctx)
+
+		nk.NkLayoutSpaceBegin(ctx, nk.Static, 0, 3)
+
+		bounds := nk.NkLayoutWidgetBounds(ctx)
+
+		nk.NkLayoutSpacePush(ctx, nk.NkRect(0, 0, bounds.W(), bounds.H()))
+		nk.NkLabel(ctx, "Password audit", nk.TextLeft)
+
+		nk.NkLayoutSpacePush(ctx, nk.NkRect(0, bounds.H()+4, bounds.W(), region.H()-bounds.H()-20))
+
+		nk.SetGroupPadding(ctx, nk.NkVec2(0, 0))
+		if nk.NkGroupBegin(ctx, "audit items", nk.WindowScrollAutoHide) > 0 {
+			if state.auditReport != nil {
+				for _, item := range state.auditReport.Items {
+					nk.NkLayoutRowDynamic(ctx, 0, 1)
+					nk.NkLabel(ctx, item.Title, nk.TextLeft)
+					nk.NkLabel(ctx, "  "+auditProblems(item), nk.TextLeft)
+				}
+			}
+			nk.NkGroupEnd(ctx)
+		}
+
+		nk.NkLayoutSpacePush(ctx, nk.NkRect(0, region.H()-28, bounds.W(), 28))
+
+		StatusLine(window, ctx, state)
+
+		nk.NkLayoutSpaceEnd(ctx)
+
+		nk.NkEnd(ctx)
+	}
+}
+
+// auditProblems describes the problems of an item password.
+func auditProblems(item audit.ItemReport) string {
+	var problems []string
+	if item.Breaches > 0 {
+		problems = append(problems, fmt.Sprintf("found in %d breaches", item.Breaches))
+	}
+	if item.Weak {
+		problems = append(problems, fmt.Sprintf("weak (%d/4)", item.Strength.Score))
+	}
+	if n := len(item.ReusedBy); n > 0 {
+		problems = append(problems, fmt.Sprintf("reused by %d items", n))
+	}
+	if item.Old {
+		problems = append(problems, fmt.Sprintf("%d days old", int(time.Since(item.UpdatedAt).Hours()/24)))
+	}
+	return strings.Join(problems, ", ")
+}
@@ -0,0 +1,181 @@
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"sort"
+	"time"
+
+	"github.com/michalnicp/1pass/op"
+	"github.com/pkg/errors"
+)
+
+// Options configure an audit.
+type Options struct {
+
+	// MinScore is the minimum strength score, from 0 to 4, of a password that isn't weak.
+	MinScore int
+
+	// MaxAge is the age after which a password is old. Passwords are never old if zero.
+	MaxAge time.Duration
+
+	// Interval is the minimum time between fetching item details.
+	Interval time.Duration
+
+	// BreachDB is checked for breached passwords if set.
+	BreachDB *BreachDB
+}
+
+// DefaultOptions are the options used by the cli and the ui.
+var DefaultOptions = Options{
+	MinScore: 3,
+	MaxAge:   365 * 24 * time.Hour,
+	Interval: 200 * time.Millisecond,
+}
+
+// ItemReport is the result of auditing the password of an item.
+type ItemReport struct {
+	UUID       string    `json:"uuid"`
+	Title      string    `json:"title"`
+	VaultUUID  string    `json:"vaultUuid"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	Strength   Strength  `json:"strength"`
+	OPStrength int       `json:"opStrength"` // strength reported by 1Password, 0 to 100
+	Weak       bool      `json:"weak"`
+	ReusedBy   []string  `json:"reusedBy,omitempty"` // uuids of the other items with the same password
+	Old        bool      `json:"old"`
+	Breaches   int       `json:"breaches"` // times the password appeared in breaches
+}
+
+// Report is the result of an audit. Only items with a weak, reused, old or breached password
+// are included.
+type Report struct {
+	CreatedAt time.Time    `json:"createdAt"`
+	Audited   int          `json:"audited"`
+	Weak      int          `json:"weak"`
+	Reused    int          `json:"reused"`
+	Old       int          `json:"old"`
+	Breached  int          `json:"breached"`
+	Items     []ItemReport `json:"items"`
+}
+
+// Run audits the passwords of every login and password item.
+//
+// Reused passwords are found by comparing keyed hashes of the passwords. The key is random
+// and only kept in memory for the duration of the audit.
+func Run(session *op.Session, opts Options) (*Report, error) {
+	items, err := session.ListItems()
+	if err != nil {
+		return nil, errors.Wrap(err, "list items")
+	}
+
+	var audited []op.Item
+	for _, item := range items {
+		if item.TemplateUUID == op.TemplateLogin || item.TemplateUUID == op.TemplatePassword {
+			audited = append(audited, item)
+		}
+	}
+
+	details, err := session.GetItems(audited, opts.Interval)
+	if err != nil {
+		return nil, errors.Wrap(err, "get items")
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, errors.Wrap(err, "generate hash key")
+	}
+
+	now := time.Now()
+	reports := make([]ItemReport, 0, len(details))
+	hashes := make(map[string][]int)
+	for i, item := range details {
+		password := Password(item)
+		if password == "" {
+			continue
+		}
+
+		report := ItemReport{
+			UUID:       item.UUID,
+			Title:      audited[i].Overview.Title,
+			VaultUUID:  audited[i].VaultUUID,
+			UpdatedAt:  audited[i].UpdatedAt,
+			Strength:   EstimateStrength(password),
+			OPStrength: audited[i].Overview.PS,
+		}
+		report.Weak = report.Strength.Score < opts.MinScore
+		report.Old = opts.MaxAge > 0 && now.Sub(report.UpdatedAt) > opts.MaxAge
+
+		if opts.BreachDB != nil {
+			report.Breaches, err = opts.BreachDB.Count(password)
+			if err != nil {
+				return nil, errors.Wrap(err, "check breached passwords")
+			}
+		}
+
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(password))
+		hash := string(mac.Sum(nil))
+		hashes[hash] = append(hashes[hash], len(reports))
+
+		reports = append(reports, report)
+	}
+
+	for _, group := range hashes {
+		if len(group) < 2 {
+			continue
+		}
+		for _, i := range group {
+			for _, j := range group {
+				if i != j {
+					reports[i].ReusedBy = append(reports[i].ReusedBy, reports[j].UUID)
+				}
+			}
+		}
+	}
+
+	report := Report{
+		CreatedAt: now,
+		Audited:   len(reports),
+	}
+	for _, r := range reports {
+		if r.Weak {
+			report.Weak++
+		}
+		if len(r.ReusedBy) > 0 {
+			report.Reused++
+		}
+		if r.Old {
+			report.Old++
+		}
+		if r.Breaches > 0 {
+			report.Breached++
+		}
+		if r.Weak || len(r.ReusedBy) > 0 || r.Old || r.Breaches > 0 {
+			report.Items = append(report.Items, r)
+		}
+	}
+
+	// Breached passwords first, then the weakest.
+	sort.SliceStable(report.Items, func(i, j int) bool {
+		a, b := report.Items[i], report.Items[j]
+		if (a.Breaches > 0) != (b.Breaches > 0) {
+			return a.Breaches > 0
+		}
+		return a.Strength.Guesses < b.Strength.Guesses
+	})
+
+	return &report, nil
+}
+
+// Password returns the password of a login or password item.
+func Password(item *op.Item) string {
+	if item.Details == nil {
+		return ""
+	}
+	if item.Details.Password != "" {
+		return item.Details.Password
+	}
+	return item.Details.Value("password")
+}
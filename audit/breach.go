@@ -0,0 +1,152 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// scanSize is the size of the region of the file that is scanned linearly instead of
+// bisected further.
+const scanSize = 4096
+
+// BreachDB is a local copy of the Have I Been Pwned passwords ordered by hash, eg.
+// pwned-passwords-sha1-ordered-by-hash-v8.txt. Each line is an upper case hex SHA-1 hash,
+// a colon and the number of times the password appeared in breaches. Lookups bisect the file
+// so it is never read into memory and no network requests are made.
+type BreachDB struct {
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenBreachDB opens the password file at path.
+func OpenBreachDB(path string) (*BreachDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	db := BreachDB{
+		f:    f,
+		size: info.Size(),
+	}
+
+	return &db, nil
+}
+
+func (db *BreachDB) Close() error {
+	return db.f.Close()
+}
+
+// Count returns the number of times the password appeared in breaches, zero if it never did.
+func (db *BreachDB) Count(password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := bytes.ToUpper([]byte(hex.EncodeToString(sum[:])))
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// The hash is on a line starting in [lo, hi), both are always line starts.
+	lo, hi := int64(0), db.size
+	for hi-lo > scanSize {
+		mid := lo + (hi-lo)/2
+
+		start, line, err := db.lineAfter(mid)
+		if err != nil {
+			return 0, err
+		}
+		if start >= hi {
+			break
+		}
+
+		switch cmp := bytes.Compare(lineHash(line), hash); {
+		case cmp == 0:
+			return lineCount(line)
+		case cmp < 0:
+			lo = start + int64(len(line)) + 1
+		default:
+			hi = start
+		}
+	}
+
+	// Scan the remaining lines.
+	scanner := bufio.NewScanner(io.NewSectionReader(db.f, lo, hi-lo))
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if bytes.Equal(lineHash(bytes.TrimRight(line, "\r")), hash) {
+			return lineCount(line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, errors.Wrap(err, "scan breach file")
+	}
+
+	return 0, nil
+}
+
+// lineAfter returns the offset and contents, without the newline, of the first line starting
+// at or after off.
+func (db *BreachDB) lineAfter(off int64) (int64, []byte, error) {
+	if off == 0 {
+		line, err := db.readLine(bufio.NewReader(io.NewSectionReader(db.f, 0, db.size)))
+		return 0, line, err
+	}
+
+	// Skip to the end of the line containing off-1.
+	r := bufio.NewReader(io.NewSectionReader(db.f, off-1, db.size-off+1))
+	skipped, err := r.ReadBytes('\n')
+	if err == io.EOF {
+		return db.size, nil, nil
+	}
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "read breach file")
+	}
+
+	line, err := db.readLine(r)
+	return off - 1 + int64(len(skipped)), line, err
+}
+
+func (db *BreachDB) readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "read breach file")
+	}
+	return bytes.TrimRight(line, "\n"), nil
+}
+
+// lineHash returns the hash of a line in upper case.
+func lineHash(line []byte) []byte {
+	if i := bytes.IndexByte(line, ':'); i >= 0 {
+		line = line[:i]
+	}
+	return bytes.ToUpper(line)
+}
+
+// lineCount returns the breach count of a line.
+func lineCount(line []byte) (int, error) {
+	i := bytes.IndexByte(line, ':')
+	if i < 0 {
+		return 0, errors.Errorf("invalid breach file line %q", line)
+	}
+
+	count, err := strconv.Atoi(string(bytes.TrimSpace(line[i+1:])))
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid breach file line %q", line)
+	}
+
+	return count, nil
+}
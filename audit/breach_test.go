@@ -0,0 +1,93 @@
+package audit
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeDump writes a password file with the passwords, in hash order, and returns its path.
+// Password i appeared i+1 times.
+func writeDump(t *testing.T, passwords []string, newline string) string {
+	lines := make([]string, len(passwords))
+	for i, password := range passwords {
+		lines[i] = fmt.Sprintf("%X:%d", sha1.Sum([]byte(password)), i+1)
+	}
+	sort.Strings(lines)
+
+	f, err := ioutil.TempFile("", "hibp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(strings.Join(lines, newline) + newline); err != nil {
+		t.Fatal(err)
+	}
+
+	return f.Name()
+}
+
+func TestBreachDB(t *testing.T) {
+	var passwords []string
+	for i := 0; i < 20000; i++ {
+		passwords = append(passwords, fmt.Sprintf("password%d", i))
+	}
+
+	for _, newline := range []string{"\n", "\r\n"} {
+		path := writeDump(t, passwords, newline)
+		defer os.Remove(path)
+
+		db, err := OpenBreachDB(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		for i, password := range passwords {
+			count, err := db.Count(password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != i+1 {
+				t.Fatalf("%q: got count %d, want %d", password, count, i+1)
+			}
+		}
+
+		for _, password := range []string{"", "not breached", "password20000"} {
+			count, err := db.Count(password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 0 {
+				t.Fatalf("%q: got count %d, want 0", password, count)
+			}
+		}
+	}
+}
+
+func TestBreachDBSmall(t *testing.T) {
+	for _, passwords := range [][]string{nil, {"one"}, {"one", "two"}} {
+		path := writeDump(t, passwords, "\n")
+		defer os.Remove(path)
+
+		db, err := OpenBreachDB(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		for i, password := range passwords {
+			if count, err := db.Count(password); err != nil || count != i+1 {
+				t.Fatalf("%q: got count %d, %v, want %d", password, count, err, i+1)
+			}
+		}
+		if count, err := db.Count("three"); err != nil || count != 0 {
+			t.Fatalf("got count %d, %v, want 0", count, err)
+		}
+	}
+}
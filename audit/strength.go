@@ -0,0 +1,225 @@
+package audit
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Strength is an estimate of how hard a password is to guess.
+type Strength struct {
+	Guesses float64 `json:"guesses"` // log10 of the estimated number of guesses
+	Score   int     `json:"score"`   // 0 (too guessable) to 4 (very unguessable)
+}
+
+// commonPasswords are frequently used passwords and words, most common first.
+var commonPasswords = []string{
+	"password", "123456", "12345678", "qwerty", "123456789", "12345", "1234", "111111",
+	"1234567", "dragon", "123123", "baseball", "abc123", "football", "monkey", "letmein",
+	"696969", "shadow", "master", "666666", "qwertyuiop", "123321", "mustang", "1234567890",
+	"michael", "654321", "superman", "1qaz2wsx", "7777777", "121212", "000000", "qazwsx",
+	"123qwe", "killer", "trustno1", "jordan", "jennifer", "zxcvbnm", "asdfgh", "hunter",
+	"buster", "soccer", "harley", "batman", "andrew", "tigger", "sunshine", "iloveyou",
+	"2000", "charlie", "robert", "thomas", "hockey", "ranger", "daniel", "starwars",
+	"klaster", "112233", "george", "computer", "michelle", "jessica", "pepper", "1111",
+	"zxcvbn", "555555", "11111111", "131313", "freedom", "777777", "pass", "maggie",
+	"159753", "aaaaaa", "ginger", "princess", "joshua", "cheese", "amanda", "summer",
+	"love", "ashley", "nicole", "chelsea", "biteme", "matthew", "access", "yankees",
+	"987654321", "dallas", "austin", "thunder", "taylor", "matrix", "admin", "welcome",
+	"login", "passw0rd", "secret", "changeme", "default", "root", "test", "guest",
+}
+
+var commonRanks = func() map[string]int {
+	ranks := make(map[string]int, len(commonPasswords))
+	for i, password := range commonPasswords {
+		ranks[password] = i + 1
+	}
+	return ranks
+}()
+
+// keyboardRows are adjacent keys on a qwerty keyboard.
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// leet maps common character substitutions to the letters they replace.
+var leet = strings.NewReplacer("4", "a", "@", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t", "+", "t")
+
+// EstimateStrength estimates the strength of a password in the spirit of zxcvbn. The password
+// is split into the segments that are cheapest to guess, where a segment is either a known
+// pattern (common password, keyboard walk, sequence, repetition or year) or bruteforced.
+func EstimateStrength(password string) Strength {
+	runes := []rune(password)
+	n := len(runes)
+
+	// best[i] is the minimum log10 guesses to guess the first i runes.
+	best := make([]float64, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = math.Inf(1)
+		for j := 0; j < i; j++ {
+			guesses := best[j] + segmentGuesses(runes[j:i])
+			if guesses < best[i] {
+				best[i] = guesses
+			}
+		}
+	}
+
+	guesses := best[n]
+	return Strength{
+		Guesses: guesses,
+		Score:   score(guesses),
+	}
+}
+
+// score converts log10 guesses to a score from 0 to 4 using the thresholds of zxcvbn.
+func score(guesses float64) int {
+	switch {
+	case guesses < 3:
+		return 0
+	case guesses < 6:
+		return 1
+	case guesses < 8:
+		return 2
+	case guesses < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// segmentGuesses returns the log10 guesses of the cheapest way to guess the segment.
+func segmentGuesses(segment []rune) float64 {
+	guesses := bruteforceGuesses(segment)
+	if g, ok := patternGuesses(segment); ok && g < guesses {
+		guesses = g
+	}
+	return guesses
+}
+
+// bruteforceGuesses returns the log10 guesses to try every combination of the character
+// classes in the segment.
+func bruteforceGuesses(segment []rune) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range segment {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	var cardinality float64
+	if lower {
+		cardinality += 26
+	}
+	if upper {
+		cardinality += 26
+	}
+	if digit {
+		cardinality += 10
+	}
+	if symbol {
+		cardinality += 33
+	}
+	if other {
+		cardinality += 100
+	}
+
+	return float64(len(segment)) * math.Log10(cardinality)
+}
+
+// patternGuesses returns the log10 guesses of the segment if it matches a known pattern.
+func patternGuesses(segment []rune) (float64, bool) {
+	s := string(segment)
+	lower := strings.ToLower(s)
+
+	// Upper case letters in a dictionary word roughly double the guesses.
+	var variations float64
+	if lower != s {
+		variations = math.Log10(2)
+	}
+
+	if rank, ok := commonRanks[lower]; ok {
+		return math.Log10(float64(rank)) + variations, true
+	}
+	if unleeted := leet.Replace(lower); unleeted != lower {
+		if rank, ok := commonRanks[unleeted]; ok {
+			return math.Log10(float64(rank)) + variations + math.Log10(2), true
+		}
+	}
+
+	n := len(segment)
+	if n < 3 {
+		return 0, false
+	}
+
+	// Repeated character, eg. aaaa.
+	repeat := true
+	for _, r := range segment[1:] {
+		if r != segment[0] {
+			repeat = false
+			break
+		}
+	}
+	if repeat {
+		return math.Log10(bruteforceCardinality(segment[0]) * float64(n)), true
+	}
+
+	// Sequence, eg. abcd or 9876.
+	delta := segment[1] - segment[0]
+	if delta == 1 || delta == -1 {
+		sequence := true
+		for i := 2; i < n; i++ {
+			if segment[i]-segment[i-1] != delta {
+				sequence = false
+				break
+			}
+		}
+		if sequence {
+			return math.Log10(bruteforceCardinality(segment[0]) * float64(n) * 2), true
+		}
+	}
+
+	// Keyboard walk, eg. qwerty.
+	if n >= 4 {
+		for _, row := range keyboardRows {
+			if strings.Contains(row, lower) || strings.Contains(reverse(row), lower) {
+				return math.Log10(100 * float64(n)), true
+			}
+		}
+	}
+
+	// Recent year, eg. 1987.
+	if n == 4 && lower >= "1900" && lower <= "2039" && strings.Trim(lower, "0123456789") == "" {
+		return math.Log10(140), true
+	}
+
+	return 0, false
+}
+
+// bruteforceCardinality returns the size of the character class of r.
+func bruteforceCardinality(r rune) float64 {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		return 26
+	case r >= '0' && r <= '9':
+		return 10
+	case r < unicode.MaxASCII:
+		return 33
+	default:
+		return 100
+	}
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/golang-ui/nuklear/nk"
+	"github.com/michalnicp/1pass/logging"
+	"github.com/michalnicp/1pass/op"
+	"github.com/michalnicp/1pass/secretref"
+	"github.com/pkg/errors"
+)
+
+// auditLogSize is the number of events shown in the audit log view.
+const auditLogSize = 100
+
+// auditLog records which fields were copied or revealed. Nil if it couldn't be opened.
+var auditLog *logging.AuditLog
+
+// auditLogPath returns the path of the audit log, $XDG_DATA_HOME/1pass/audit.log.
+func auditLogPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", errors.Wrap(err, "data directory")
+	}
+	return filepath.Join(dir, "audit.log"), nil
+}
+
+// openAuditLog opens the audit log. Access is still allowed if it can't be opened.
+func openAuditLog() {
+	path, err := auditLogPath()
+	if err != nil {
+		logging.Error("open audit log", "err", err)
+		return
+	}
+
+	auditLog, err = logging.OpenAuditLog(path)
+	if err != nil {
+		logging.Error("open audit log", "err", err)
+	}
+}
+
+// recordAccess records that the field of the item was copied or revealed.
+func recordAccess(action string, item *op.Item, field, source string) {
+	logging.Debug(action, "item", item.UUID, "field", field, "source", source)
+
+	err := auditLog.Record(logging.AuditEvent{
+		Action: action,
+		Item:   item.UUID,
+		Title:  item.Overview.Title,
+		Field:  field,
+		Source: source,
+	})
+	if err != nil {
+		logging.Error("record access", "err", err)
+	}
+}
+
+// revealFunc returns a resolver callback recording the revealed references for the command.
+func revealFunc(command string) func(item *op.Item, ref secretref.Reference) {
+	return func(item *op.Item, ref secretref.Reference) {
+		field := ref.Field
+		if ref.Section != "" {
+			field = ref.Section + "/" + ref.Field
+		}
+		recordAccess(logging.ActionReveal, item, field, command)
+	}
+}
+
+// showAuditLog reads the latest events and shows them.
+func (s *UIState) showAuditLog() {
+	path, err := auditLogPath()
+	if err == nil {
+		s.auditEvents, err = logging.ReadAuditLog(path, auditLogSize)
+	}
+	if err != nil {
+		logging.Error("read audit log", "err", err)
+		s.statusText = fmt.Sprintf("read audit log: %v", err)
+	}
+
+	s.isShowingAuditLog = true
+}
+
+// AuditLog draws the latest copied or revealed fields.
+func AuditLog(window *glfw.Window, ctx *nk.Context, state *UIState) {
+	width, height := window.GetSize()
+	bounds := nk.NkRect(0, 0, float32(width), float32(height))
+	if nk.NkBegin(ctx, "audit log", bounds, 0) > 0 {
+		nk.NkLayoutRowDynamic(ctx, 0, 1)
+		nk.NkLabel(ctx, "Audit log, press escape to go back", nk.TextLeft)
+
+		if len(state.auditEvents) == 0 {
+			nk.NkLabel(ctx, "No fields were copied or revealed.", nk.TextLeft)
+		}
+
+		for _, event := range state.auditEvents {
+			nk.NkLabel(ctx, fmt.Sprintf("%s %s %s", event.Time.Format("2006-01-02 15:04"), event.Action, event.Field), nk.TextLeft)
+			nk.NkLabel(ctx, fmt.Sprintf("  %s (%s)", event.Title, event.Source), nk.TextLeft)
+		}
+
+		nk.NkEnd(ctx)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/michalnicp/1pass/backup"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// stdin buffers stdin when passphrases are piped in.
+var stdin = bufio.NewReader(os.Stdin)
+
+// backupItems writes an encrypted snapshot of every item to the backup directory.
+//
+//  1pass backup --keep 10
+func backupItems(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	dir := flags.String("dir", "", "backup directory, defaults to $XDG_DATA_HOME/1pass/backups")
+	keep := flags.Int("keep", 10, "number of snapshots to keep, 0 to keep all")
+	interval := flags.Duration("interval", 200*time.Millisecond, "minimum time between fetching items")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := backupDir(dir); err != nil {
+		return err
+	}
+
+	passphrase, err := readPassphrase("Backup passphrase: ")
+	if err != nil {
+		return err
+	}
+	if terminal.IsTerminal(int(os.Stdin.Fd())) {
+		confirm, err := readPassphrase("Repeat passphrase: ")
+		if err != nil {
+			return err
+		}
+		if passphrase != confirm {
+			return errors.New("passphrases don't match")
+		}
+	}
+
+	session, err := cliSession()
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := backup.New(session, *interval)
+	if err != nil {
+		return errors.Wrap(err, "create snapshot")
+	}
+
+	path, err := backup.Save(*dir, snapshot, passphrase, *keep)
+	if err != nil {
+		return errors.Wrap(err, "save snapshot")
+	}
+
+	fmt.Printf("saved %d items to %s\n", len(snapshot.Items), path)
+
+	return nil
+}
+
+// restoreItems compares a snapshot with the account and recreates the missing items.
+//
+//  1pass restore --dry-run [snapshot]
+//
+// The latest snapshot in the backup directory is used if none is given. Restored items are
+// recorded in a log next to the snapshot, they aren't restored again by a later run.
+func restoreItems(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	dir := flags.String("dir", "", "backup directory, defaults to $XDG_DATA_HOME/1pass/backups")
+	dryRun := flags.Bool("dry-run", false, "only show the difference between the snapshot and the account")
+
+	args, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+
+	var path string
+	switch len(args) {
+	case 0:
+		if err := backupDir(dir); err != nil {
+			return err
+		}
+		paths, err := backup.List(*dir)
+		if err != nil {
+			return errors.Wrap(err, "list snapshots")
+		}
+		if len(paths) == 0 {
+			return errors.Errorf("no snapshots in %s", *dir)
+		}
+		path = paths[len(paths)-1]
+	case 1:
+		path = args[0]
+	default:
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	passphrase, err := readPassphrase("Backup passphrase: ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	snapshot, err := backup.Read(f, passphrase)
+	if err != nil {
+		return errors.Wrap(err, "read snapshot")
+	}
+
+	session, err := cliSession()
+	if err != nil {
+		return err
+	}
+
+	items, err := session.ListItems()
+	if err != nil {
+		return errors.Wrap(err, "list items")
+	}
+
+	openRestored := backup.OpenRestored
+	if *dryRun {
+		openRestored = backup.ReadRestored
+	}
+	restored, err := openRestored(path + backup.RestoredSuffix)
+	if err != nil {
+		return errors.Wrap(err, "open restore log")
+	}
+	defer restored.Close()
+
+	diff := backup.Compare(snapshot, items, restored)
+
+	fmt.Printf("snapshot %s from %s\n", filepath.Base(path), snapshot.CreatedAt.Local().Format(time.RFC1123))
+	for _, item := range diff.Changed {
+		fmt.Printf("changed  %s\n", item.Overview.Title)
+	}
+	for _, item := range diff.Added {
+		fmt.Printf("added    %s\n", item.Overview.Title)
+	}
+	for _, item := range diff.Missing {
+		fmt.Printf("missing  %s\n", item.Overview.Title)
+	}
+	fmt.Printf("%d missing, %d changed, %d added since the snapshot\n", len(diff.Missing), len(diff.Changed), len(diff.Added))
+
+	if *dryRun {
+		return nil
+	}
+
+	vaults, err := session.ListVaults()
+	if err != nil {
+		return errors.Wrap(err, "list vaults")
+	}
+
+	var failed int
+	for _, item := range diff.Missing {
+		if _, err := backup.Restore(session, vaults, restored, item); err != nil {
+			fmt.Fprintf(os.Stderr, "restore %q: %v\n", item.Overview.Title, err)
+			failed++
+			continue
+		}
+		fmt.Printf("restored %s\n", item.Overview.Title)
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d items could not be restored", failed)
+	}
+
+	return nil
+}
+
+// backupDir sets dir to the default backup directory if empty.
+func backupDir(dir *string) error {
+	if *dir != "" {
+		return nil
+	}
+
+	data, err := dataDir()
+	if err != nil {
+		return errors.Wrap(err, "data directory")
+	}
+	*dir = filepath.Join(data, "backups")
+
+	return nil
+}
+
+// readPassphrase prompts for a passphrase without echoing it. When stdin isn't a terminal a
+// line is read from it instead.
+func readPassphrase(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return "", errors.Wrap(err, "read passphrase")
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", errors.Wrap(err, "read passphrase")
+	}
+	if len(passphrase) == 0 {
+		return "", errors.New("empty passphrase")
+	}
+
+	return string(passphrase), nil
+}
@@ -0,0 +1,214 @@
+package backup
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/michalnicp/1pass/op"
+	"github.com/pkg/errors"
+)
+
+const (
+	// version is the version of the snapshot format.
+	version = 1
+
+	filePrefix = "1pass-"
+	fileSuffix = ".json.gz.age"
+
+	// timeFormat is used in snapshot file names so they sort by time.
+	timeFormat = "20060102T150405Z"
+
+	// maxPerSecond is the number of snapshots that can be saved within a second. They are
+	// numbered _02, _03 and so on, which sorts after the first.
+	maxPerSecond = 99
+)
+
+// Snapshot is a copy of every item with its details.
+type Snapshot struct {
+	Version   int        `json:"version"`
+	CreatedAt time.Time  `json:"createdAt"`
+	Email     string     `json:"email"`
+	Vaults    []op.Vault `json:"vaults"`
+	Items     []*op.Item `json:"items"`
+}
+
+// New returns a snapshot of every item in every vault. Item details are fetched at most once
+// per interval.
+func New(session *op.Session, interval time.Duration) (*Snapshot, error) {
+	vaults, err := session.ListVaults()
+	if err != nil {
+		return nil, errors.Wrap(err, "list vaults")
+	}
+
+	items, err := session.ListItems()
+	if err != nil {
+		return nil, errors.Wrap(err, "list items")
+	}
+
+	details, err := session.GetItems(items, interval)
+	if err != nil {
+		return nil, errors.Wrap(err, "get items")
+	}
+
+	snapshot := Snapshot{
+		Version:   version,
+		CreatedAt: time.Now().UTC(),
+		Email:     session.Email,
+		Vaults:    vaults,
+		Items:     details,
+	}
+
+	return &snapshot, nil
+}
+
+// Write writes the snapshot as gzipped json encrypted with age using the passphrase.
+func Write(w io.Writer, snapshot *Snapshot, passphrase string) error {
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return errors.Wrap(err, "create recipient")
+	}
+
+	encrypted, err := age.Encrypt(w, recipient)
+	if err != nil {
+		return errors.Wrap(err, "encrypt")
+	}
+
+	gz := gzip.NewWriter(encrypted)
+	if err := json.NewEncoder(gz).Encode(snapshot); err != nil {
+		return errors.Wrap(err, "encode snapshot")
+	}
+
+	if err := gz.Close(); err != nil {
+		return errors.Wrap(err, "compress snapshot")
+	}
+
+	return encrypted.Close()
+}
+
+// Read decrypts and reads a snapshot written by Write.
+func Read(r io.Reader, passphrase string) (*Snapshot, error) {
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, errors.Wrap(err, "create identity")
+	}
+
+	decrypted, err := age.Decrypt(r, identity)
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypt")
+	}
+
+	gz, err := gzip.NewReader(decrypted)
+	if err != nil {
+		return nil, errors.Wrap(err, "decompress snapshot")
+	}
+	defer gz.Close()
+
+	var snapshot Snapshot
+	if err := json.NewDecoder(gz).Decode(&snapshot); err != nil {
+		return nil, errors.Wrap(err, "decode snapshot")
+	}
+	if snapshot.Version != version {
+		return nil, errors.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+
+	return &snapshot, nil
+}
+
+// Save writes the snapshot to a new file in dir and removes the oldest snapshots so at most
+// keep remain. It returns the path of the new file.
+func Save(dir string, snapshot *Snapshot, passphrase string, keep int) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", errors.Wrap(err, "create backup directory")
+	}
+
+	f, err := ioutil.TempFile(dir, ".snapshot")
+	if err != nil {
+		return "", errors.Wrap(err, "create snapshot file")
+	}
+	defer os.Remove(f.Name())
+
+	if err := Write(f, snapshot, passphrase); err != nil {
+		f.Close()
+		return "", err
+	}
+
+	if err := f.Close(); err != nil {
+		return "", errors.Wrap(err, "close snapshot file")
+	}
+
+	// Linking fails instead of replacing a snapshot saved within the same second.
+	var path string
+	for n := 1; ; n++ {
+		name := filePrefix + snapshot.CreatedAt.Format(timeFormat)
+		if n > 1 {
+			name += fmt.Sprintf("_%02d", n)
+		}
+		path = filepath.Join(dir, name+fileSuffix)
+
+		err := os.Link(f.Name(), path)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) || n == maxPerSecond {
+			return "", errors.Wrap(err, "link snapshot file")
+		}
+	}
+
+	if err := rotate(dir, keep); err != nil {
+		return "", errors.Wrap(err, "rotate snapshots")
+	}
+
+	return path, nil
+}
+
+// List returns the paths of the snapshots in dir, oldest first.
+func List(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileSuffix) {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// rotate removes the oldest snapshots in dir, and their restore logs, so at most keep remain.
+func rotate(dir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	paths, err := List(dir)
+	if err != nil {
+		return err
+	}
+
+	for len(paths) > keep {
+		if err := os.Remove(paths[0]); err != nil {
+			return err
+		}
+		if err := os.Remove(paths[0] + RestoredSuffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		paths = paths[1:]
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/michalnicp/1pass/op"
+	"github.com/michalnicp/1pass/op/optest"
+)
+
+func TestMain(m *testing.M) {
+	optest.Main(m)
+}
+
+func TestSaveSameSecond(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	createdAt := time.Date(2018, 12, 22, 10, 0, 0, 0, time.UTC)
+
+	var saved []string
+	for i := 0; i < 3; i++ {
+		snapshot := &Snapshot{
+			Version:   version,
+			CreatedAt: createdAt,
+			Email:     "user@example.com",
+			Items:     []*op.Item{{UUID: string(rune('a' + i))}},
+		}
+
+		path, err := Save(dir, snapshot, "passphrase", 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved = append(saved, path)
+	}
+
+	if saved[0] == saved[1] || saved[1] == saved[2] {
+		t.Fatalf("snapshots saved to the same file: %q", saved)
+	}
+
+	// The oldest snapshot is rotated, the newer ones are kept in order.
+	paths, err := List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 || paths[0] != saved[1] || paths[1] != saved[2] {
+		t.Fatalf("got snapshots %q, saved %q", paths, saved)
+	}
+
+	for i, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snapshot, err := Read(f, "passphrase")
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := string(rune('b' + i)); snapshot.Items[0].UUID != want {
+			t.Fatalf("%s: got item %q, want %q", filepath.Base(path), snapshot.Items[0].UUID, want)
+		}
+	}
+
+	// No temporary files are left.
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d files, want 2", len(entries))
+	}
+}
+
+// TestRestoreTwice restores the missing items of a snapshot twice, the second run finds
+// nothing missing.
+func TestRestoreTwice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot"+RestoredSuffix)
+
+	kept := op.Item{TemplateUUID: op.TemplateLogin, Details: &op.Details{}}
+	kept.Overview.Title = "kept"
+
+	fake, err := optest.New(nil, []op.Item{kept}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	session, err := fake.Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := fake.Items()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted := &op.Item{UUID: "deleted", TemplateUUID: op.TemplateLogin, VaultUUID: "gone", Details: &op.Details{}}
+	deleted.Overview.Title = "deleted"
+	snapshot := &Snapshot{Items: []*op.Item{&items[0], deleted}}
+
+	for run := 0; run < 2; run++ {
+		restored, err := OpenRestored(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		items, err := session.ListItems()
+		if err != nil {
+			t.Fatal(err)
+		}
+		vaults, err := session.ListVaults()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		diff := Compare(snapshot, items, restored)
+		if len(diff.Added) != 0 || len(diff.Changed) != 0 {
+			t.Fatalf("run %d: got %d added, %d changed", run, len(diff.Added), len(diff.Changed))
+		}
+		want := 1 - run
+		if len(diff.Missing) != want {
+			t.Fatalf("run %d: got %d missing, want %d", run, len(diff.Missing), want)
+		}
+
+		for _, item := range diff.Missing {
+			created, err := Restore(session, vaults, restored, item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The vault no longer exists, the item is created in the default one.
+			if created.VaultUUID != items[0].VaultUUID {
+				t.Errorf("restored to vault %q", created.VaultUUID)
+			}
+		}
+		restored.Close()
+	}
+
+	if items, _ := fake.Items(); len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+
+	// A dry run reads the log without changing it.
+	restored, err := ReadRestored(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.UUID(deleted) == "" {
+		t.Fatal("restored item not in the log")
+	}
+	if err := restored.Add(&kept, "uuid"); err == nil {
+		t.Fatal("added to a read only log")
+	}
+}
@@ -0,0 +1,81 @@
+package backup
+
+import (
+	"github.com/michalnicp/1pass/op"
+	"github.com/pkg/errors"
+)
+
+// Diff is the difference between a snapshot and the live account.
+type Diff struct {
+	Missing []*op.Item // in the snapshot but not in the account
+	Changed []*op.Item // updated in the account since the snapshot, as in the snapshot
+	Added   []op.Item  // in the account but not in the snapshot
+}
+
+// Compare compares the snapshot with the items in the account. Items restored from the
+// snapshot, as recorded in the restore log, are neither missing nor added while their copy
+// exists.
+func Compare(snapshot *Snapshot, items []op.Item, restored *Restored) Diff {
+	live := make(map[string]op.Item)
+	for _, item := range items {
+		live[item.UUID] = item
+	}
+
+	saved := make(map[string]bool)
+
+	var diff Diff
+	for _, item := range snapshot.Items {
+		saved[item.UUID] = true
+
+		if uuid := restored.UUID(item); uuid != "" {
+			if _, ok := live[uuid]; ok {
+				saved[uuid] = true
+				continue
+			}
+		}
+
+		liveItem, ok := live[item.UUID]
+		switch {
+		case !ok:
+			diff.Missing = append(diff.Missing, item)
+		case !liveItem.UpdatedAt.Equal(item.UpdatedAt):
+			diff.Changed = append(diff.Changed, item)
+		}
+	}
+
+	for _, item := range items {
+		if !saved[item.UUID] {
+			diff.Added = append(diff.Added, item)
+		}
+	}
+
+	return diff
+}
+
+// Restore recreates the item in the account and records it in the restore log. Items are
+// created in their original vault if it is one of vaults, in the default vault otherwise. The
+// new item has a new uuid.
+func Restore(session *op.Session, vaults []op.Vault, restored *Restored, item *op.Item) (*op.Item, error) {
+	category, ok := op.Categories[item.TemplateUUID]
+	if !ok {
+		return nil, errors.Errorf("items with template %s can't be restored", item.TemplateUUID)
+	}
+
+	var vault string
+	for _, v := range vaults {
+		if v.UUID == item.VaultUUID {
+			vault = v.UUID
+			break
+		}
+	}
+
+	created, err := session.CreateItem(category, vault, item)
+	if err != nil {
+		return nil, err
+	}
+	if err := restored.Add(item, created.UUID); err != nil {
+		return created, errors.Wrap(err, "write restore log")
+	}
+
+	return created, nil
+}
@@ -0,0 +1,102 @@
+package backup
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/michalnicp/1pass/op"
+	"github.com/pkg/errors"
+)
+
+// RestoredSuffix is appended to the path of a snapshot for its restore log.
+const RestoredSuffix = ".restored"
+
+// Restored is an append only log of the items restored from a snapshot, used to not restore
+// an item twice. Each line is the uuid of the item in the snapshot and the uuid of the new
+// item.
+type Restored struct {
+	f     *os.File
+	uuids map[string]string
+}
+
+// OpenRestored opens the restore log at path, creating it if it doesn't exist.
+func OpenRestored(path string) (*Restored, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	r := Restored{f: f}
+	if err := r.read(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// ReadRestored reads the restore log at path without creating or changing it, eg. for a dry
+// run. A missing log has no items. Items can't be added to the returned log.
+func ReadRestored(path string) (*Restored, error) {
+	var r Restored
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		r.uuids = make(map[string]string)
+		return &r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := r.read(f); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func (r *Restored) read(rd io.Reader) error {
+	r.uuids = make(map[string]string)
+
+	scanner := bufio.NewScanner(rd)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 {
+			r.uuids[fields[0]] = fields[1]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, "read restore log")
+	}
+
+	return nil
+}
+
+// UUID returns the uuid of the item the snapshot item was restored as, or "" if it wasn't.
+func (r *Restored) UUID(item *op.Item) string {
+	return r.uuids[item.UUID]
+}
+
+// Add records the snapshot item as restored as the item with the uuid.
+func (r *Restored) Add(item *op.Item, uuid string) error {
+	if r.f == nil {
+		return errors.New("restore log is read only")
+	}
+
+	if _, err := fmt.Fprintf(r.f, "%s %s\n", item.UUID, uuid); err != nil {
+		return err
+	}
+	r.uuids[item.UUID] = uuid
+	return r.f.Sync()
+}
+
+func (r *Restored) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	return r.f.Close()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/michalnicp/1pass/op"
+	"github.com/pkg/errors"
+)
+
+// command is a subcommand that runs instead of the ui.
+type command func(args []string) error
+
+// exitError is returned by a command to exit with the code without printing an error.
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// commands maps subcommand names to their implementation.
+var commands = map[string]command{
+	"git-credential":    gitCredential,
+	"docker-credential": dockerCredential,
+	"run":               run,
+	"inject":            inject,
+	"export-secret":     exportSecret,
+	"audit":             runAudit,
+	"export":            exportItems,
+	"import":            importItems,
+	"backup":            backupItems,
+	"restore":           restoreItems,
+	"lock":              lockSession,
+	"match":             matchItems,
+	"native-messaging":  nativeMessaging,
+}
+
+// helpers maps executable names to the subcommand they run. Tools such as git run helpers by
+// name, so the binary is symlinked as each of these.
+var helpers = map[string]string{
+	"git-credential-1pass":    "git-credential",
+	"docker-credential-1pass": "docker-credential",
+	"1pass-native-messaging":  "native-messaging",
+}
+
+// lookupCommand returns the subcommand and its arguments for the process arguments.
+func lookupCommand(args []string) (command, []string, bool) {
+	if name, ok := helpers[filepath.Base(args[0])]; ok {
+		return commands[name], args[1:], true
+	}
+
+	if len(args) > 1 {
+		if cmd, ok := commands[args[1]]; ok {
+			return cmd, args[2:], true
+		}
+	}
+
+	return nil, nil, false
+}
+
+// parseInterspersed parses the flags allowing them to follow the positional arguments, which
+// are returned. Arguments after -- are never parsed as flags.
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+
+		rest := flags.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			return append(positional, rest...), nil
+		}
+
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// cliSession returns the session of the latest signin from the op config. The session token
+// is read from the environment, as set by `eval $(op signin)`.
+func cliSession() (*op.Session, error) {
+	session, err := op.NewSessionFromConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "create session")
+	}
+
+	if !session.HasToken() {
+		return nil, errors.New("not signed in, run `eval $(op signin)` first")
+	}
+
+	return session, nil
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"io"
 	"os/exec"
 	"strconv"
 	"time"
@@ -9,8 +8,20 @@ import (
 	"github.com/pkg/errors"
 )
 
-func writeClipboard(text string) error {
-	cmd := exec.Command("xsel", "--input", "--clipboard")
+// clipboardCommands are the commands copying stdin to the clipboard for each tool.
+var clipboardCommands = map[string][]string{
+	"xsel":    {"xsel", "--input", "--clipboard"},
+	"xclip":   {"xclip", "-selection", "clipboard"},
+	"wl-copy": {"wl-copy"},
+}
+
+func writeClipboard(tool string, text []byte) error {
+	args, ok := clipboardCommands[tool]
+	if !ok {
+		return errors.Errorf("unknown clipboard tool %q", tool)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -19,18 +30,18 @@ func writeClipboard(text string) error {
 
 	go func() {
 		defer stdin.Close()
-		io.WriteString(stdin, text)
+		stdin.Write(text)
 	}()
 
 	_, err = cmd.Output()
 	if err != nil {
-		return errors.Wrap(err, "xsel error")
+		return errors.Wrap(err, tool+" error")
 	}
 
 	return nil
 }
 
-func writeClipboardTimeout(text string, timeout time.Duration) error {
+func writeClipboardTimeout(text []byte, timeout time.Duration) error {
 	timeoutStr := strconv.Itoa(int(timeout / time.Millisecond))
 	cmd := exec.Command("xsel", "--input", "--clipboard", "--selectionTimeout", timeoutStr)
 
@@ -41,7 +52,7 @@ func writeClipboardTimeout(text string, timeout time.Duration) error {
 
 	go func() {
 		defer stdin.Close()
-		io.WriteString(stdin, text)
+		stdin.Write(text)
 	}()
 
 	_, err = cmd.Output()
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/michalnicp/1pass/config"
+	"github.com/michalnicp/1pass/logging"
+	"github.com/michalnicp/1pass/op"
+)
+
+// loadConfig reads the config file. It returns the settings of the file and the settings in
+// effect, where flags given on the command line take precedence over the file.
+func loadConfig(path string) (file, c *config.Config, err error) {
+	file, err = config.Load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, err = withFlags(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return file, c, nil
+}
+
+// withFlags returns a copy of the settings of the config file with the flags applied, once
+// they are validated. The file settings are left unchanged, so they can be saved without the
+// flags.
+func withFlags(file *config.Config) (*config.Config, error) {
+	c := *file
+	applyFlags(&c)
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// applyFlags overrides the settings with the flags given on the command line.
+func applyFlags(c *config.Config) {
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "ssh-agent":
+			c.SSHAgent.Socket = *sshAgentSocket
+		case "ssh-agent-vaults":
+			c.SSHAgent.Vaults = nil
+			if *sshAgentVaults != "" {
+				c.SSHAgent.Vaults = strings.Split(*sshAgentVaults, ",")
+			}
+		case "hibp":
+			c.HIBP = *hibpFile
+		case "lock-timeout":
+			c.LockTimeout.Duration = *lockTimeout
+		case "log-level":
+			c.LogLevel = *logLevel
+		case "offline":
+			c.Offline = *offline
+		}
+	})
+}
+
+// applyOptions applies the settings of the packages used.
+func applyOptions(c *config.Config) {
+	level, err := logging.ParseLevel(c.LogLevel)
+	if err == nil {
+		logging.SetLevel(level)
+	}
+
+	op.SetOptions(op.Options{
+		CacheExpiration:      c.Cache.Expiration.Duration,
+		CacheCleanupInterval: c.Cache.CleanupInterval.Duration,
+		SessionLifetime:      c.Session.Lifetime.Duration,
+		Fuzziness:            c.Search.Fuzziness,
+		EquivalentDomains:    c.Search.EquivalentDomains,
+	})
+}
+
+// applyConfig applies the settings that can change while running. The font, tray, hibp file,
+// ssh agent and secret service only change after a restart.
+func (s *UIState) applyConfig(window *glfw.Window, c *config.Config) {
+	if s.config.Font != c.Font || s.config.Tray != c.Tray || s.config.HIBP != c.HIBP ||
+		s.config.SSHAgent.Socket != c.SSHAgent.Socket ||
+		strings.Join(s.config.SSHAgent.Vaults, ",") != strings.Join(c.SSHAgent.Vaults, ",") ||
+		s.config.SecretService.Enabled != c.SecretService.Enabled ||
+		strings.Join(s.config.SecretService.Vaults, ",") != strings.Join(c.SecretService.Vaults, ",") {
+		logging.Warn("some settings only change after restarting")
+	}
+
+	applyOptions(c)
+
+	if width, height := window.GetSize(); width != c.Width || height != c.Height {
+		window.SetSize(c.Width, c.Height)
+		centerWindow(window)
+	}
+
+	s.config = c
+}
+
+// watchConfig reloads the config file when it changes until the returned watcher is closed.
+// It returns nil if the file can't be watched.
+func watchConfig(path string, window *glfw.Window, state *UIState) io.Closer {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		logging.Error("create config directory", "err", err)
+		return nil
+	}
+
+	watcher, err := config.Watch(path, func(file *config.Config, err error) {
+		var c *config.Config
+		if err == nil {
+			c, err = withFlags(file)
+		}
+		if err != nil {
+			logging.Error("reload config", "err", err)
+			state.queue(func() {
+				state.statusText = fmt.Sprintf("config: %v", err)
+			})
+			return
+		}
+
+		logging.Info("config reloaded", "path", path)
+		state.queue(func() {
+			state.fileConfig = file
+			state.applyConfig(window, c)
+		})
+	})
+	if err != nil {
+		logging.Error("watch config", "err", err)
+		return nil
+	}
+
+	return watcher
+}
@@ -0,0 +1,253 @@
+// Package config reads the settings of 1pass from $XDG_CONFIG_HOME/1pass/config.toml.
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+// ClipboardTools are the supported tools to copy to the clipboard.
+var ClipboardTools = []string{"xsel", "xclip", "wl-copy"}
+
+// TrayBackends are the supported system tray backends.
+var TrayBackends = []string{"auto", "gtk", "sni"}
+
+// LogLevels are the valid log levels.
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+// Config are the settings of 1pass. Durations are written as strings such as "5m".
+type Config struct {
+
+	// Width and Height are the size of the window.
+	Width  int `toml:"width"`
+	Height int `toml:"height"`
+
+	// Font is the path of the ttf font used in the window.
+	Font string `toml:"font"`
+
+	// Clipboard is the tool used to copy to the clipboard.
+	Clipboard string `toml:"clipboard"`
+
+	// LogLevel is the minimum level of logged messages.
+	LogLevel string `toml:"log_level"`
+
+	// Tray is the system tray backend, a StatusNotifierItem over D-Bus (sni), a
+	// GtkStatusIcon (gtk) or sni if a host is running and gtk otherwise (auto).
+	Tray string `toml:"tray"`
+
+	// LockTimeout is how long the window may be idle before locking, never if zero.
+	LockTimeout Duration `toml:"lock_timeout"`
+
+	// Offline keeps an encrypted copy of the items to use when 1Password can't be reached.
+	Offline bool `toml:"offline"`
+
+	// HIBP is the Have I Been Pwned password file to check passwords against.
+	HIBP string `toml:"hibp"`
+
+	Cache         Cache         `toml:"cache"`
+	Session       Session       `toml:"session"`
+	Search        Search        `toml:"search"`
+	SSHAgent      SSHAgent      `toml:"ssh_agent"`
+	SecretService SecretService `toml:"secret_service"`
+}
+
+// Cache configures the in memory cache of items.
+type Cache struct {
+
+	// Expiration is how long fetched items are cached.
+	Expiration Duration `toml:"expiration"`
+
+	// CleanupInterval is how often expired items are removed.
+	CleanupInterval Duration `toml:"cleanup_interval"`
+}
+
+// Session configures the 1Password session.
+type Session struct {
+
+	// Lifetime is how long a session is valid after signing in.
+	Lifetime Duration `toml:"lifetime"`
+}
+
+// Search configures searching items.
+type Search struct {
+
+	// Fuzziness is the number of typos allowed in a search term.
+	Fuzziness int `toml:"fuzziness"`
+
+	// EquivalentDomains are groups of registrable domains that share logins.
+	EquivalentDomains [][]string `toml:"equivalent_domains"`
+}
+
+// SSHAgent configures the ssh agent.
+type SSHAgent struct {
+
+	// Socket is the unix socket of the agent, the agent isn't started if empty.
+	Socket string `toml:"socket"`
+
+	// Vaults restricts the keys to the items in these vaults, all vaults if empty.
+	Vaults []string `toml:"vaults"`
+}
+
+// SecretService configures the freedesktop secret service.
+type SecretService struct {
+
+	// Enabled serves the secret service on the session bus.
+	Enabled bool `toml:"enabled"`
+
+	// Vaults are served as collections, the first one is the default collection.
+	Vaults []string `toml:"vaults"`
+}
+
+// Duration is a time.Duration read from and written as a string.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	var err error
+	d.Duration, err = time.ParseDuration(string(text))
+	return err
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.Duration.String()), nil
+}
+
+// Default returns the default settings.
+func Default() *Config {
+	return &Config{
+		Width:       400,
+		Height:      400,
+		Font:        "assets/FreeSans.ttf",
+		Clipboard:   "xsel",
+		LogLevel:    "info",
+		Tray:        "auto",
+		LockTimeout: Duration{5 * time.Minute},
+		Cache: Cache{
+			Expiration:      Duration{15 * time.Minute},
+			CleanupInterval: Duration{5 * time.Minute},
+		},
+		Session: Session{
+			Lifetime: Duration{30 * time.Minute},
+		},
+		Search: Search{
+			Fuzziness: 2,
+		},
+		SecretService: SecretService{
+			Vaults: []string{"Secret Service"},
+		},
+	}
+}
+
+// Path returns the path of the config file, $XDG_CONFIG_HOME/1pass/config.toml.
+func Path() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		user, err := user.Current()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(user.HomeDir, ".config")
+	}
+
+	return filepath.Join(dir, "1pass", "config.toml"), nil
+}
+
+// Load reads the config file at path. Settings missing from the file keep their default
+// value, the defaults are returned if the file doesn't exist. The settings aren't validated,
+// so they can be overridden first, see Validate.
+func Load(path string) (*Config, error) {
+	c := Default()
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, errors.Wrap(err, "read config")
+	}
+
+	md, err := toml.Decode(string(b), c)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse config")
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, errors.Errorf("unknown setting %q", undecoded[0].String())
+	}
+
+	return c, nil
+}
+
+// Validate returns an error describing the first invalid setting.
+func (c *Config) Validate() error {
+	switch {
+	case c.Width < 100 || c.Height < 100:
+		return errors.Errorf("window size %dx%d is too small", c.Width, c.Height)
+	case !contains(ClipboardTools, c.Clipboard):
+		return errors.Errorf("unknown clipboard tool %q", c.Clipboard)
+	case !contains(LogLevels, c.LogLevel):
+		return errors.Errorf("unknown log level %q", c.LogLevel)
+	case !contains(TrayBackends, c.Tray):
+		return errors.Errorf("unknown tray backend %q", c.Tray)
+	case c.LockTimeout.Duration < 0:
+		return errors.New("lock timeout is negative")
+	case c.Cache.Expiration.Duration <= 0:
+		return errors.New("cache expiration must be positive")
+	case c.Cache.CleanupInterval.Duration <= 0:
+		return errors.New("cache cleanup interval must be positive")
+	case c.Session.Lifetime.Duration <= 0:
+		return errors.New("session lifetime must be positive")
+	case c.Search.Fuzziness < 0 || c.Search.Fuzziness > 2:
+		return errors.Errorf("search fuzziness %d is not between 0 and 2", c.Search.Fuzziness)
+	}
+	return nil
+}
+
+// Save validates the config and writes it to path.
+func (c *Config) Save(path string) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(c); err != nil {
+		return errors.Wrap(err, "encode config")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.Wrap(err, "create config directory")
+	}
+
+	// Replace the file at once, so a watcher never reads half of it.
+	f, err := ioutil.TempFile(filepath.Dir(path), ".config")
+	if err != nil {
+		return errors.Wrap(err, "create config file")
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return errors.Wrap(err, "write config file")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "close config file")
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestLoad(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "config.toml")
+
+	// A missing file has the defaults.
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, Default()) {
+		t.Fatalf("got %+v, want the defaults", c)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("defaults are invalid: %v", err)
+	}
+
+	tests := []struct {
+		file string
+		err  string // of Load
+		want func(c *Config)
+	}{
+		// Settings missing from the file keep their default.
+		{file: "width = 800\n[cache]\nexpiration = \"1h\"\n", want: func(c *Config) {
+			c.Width = 800
+			c.Cache.Expiration.Duration = time.Hour
+		}},
+		{file: "[ssh_agent]\nsocket = \"/run/agent\"\nvaults = [\"Private\"]\n", want: func(c *Config) {
+			c.SSHAgent.Socket = "/run/agent"
+			c.SSHAgent.Vaults = []string{"Private"}
+		}},
+
+		{file: "widht = 800\n", err: `unknown setting "widht"`},
+		{file: "[cache]\nexpiry = \"1h\"\n", err: `unknown setting "cache.expiry"`},
+		{file: "width = \"800\"\n", err: "parse config"},
+		{file: "lock_timeout = \"5 minutes\"\n", err: "parse config"},
+	}
+	for _, test := range tests {
+		if err := ioutil.WriteFile(path, []byte(test.file), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := Load(path)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%q: got error %v, want %q", test.file, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.file, err)
+			continue
+		}
+
+		want := Default()
+		test.want(want)
+		if !reflect.DeepEqual(c, want) {
+			t.Errorf("%q: got %+v, want %+v", test.file, c, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		change func(c *Config)
+		err    string
+	}{
+		{func(c *Config) { c.Width = 10 }, "window size 10x400 is too small"},
+		{func(c *Config) { c.Clipboard = "pbcopy" }, `unknown clipboard tool "pbcopy"`},
+		{func(c *Config) { c.LogLevel = "trace" }, `unknown log level "trace"`},
+		{func(c *Config) { c.Tray = "qt" }, `unknown tray backend "qt"`},
+		{func(c *Config) { c.LockTimeout.Duration = -time.Minute }, "lock timeout is negative"},
+		{func(c *Config) { c.Cache.Expiration.Duration = 0 }, "cache expiration must be positive"},
+		{func(c *Config) { c.Cache.CleanupInterval.Duration = 0 }, "cache cleanup interval must be positive"},
+		{func(c *Config) { c.Session.Lifetime.Duration = 0 }, "session lifetime must be positive"},
+		{func(c *Config) { c.Search.Fuzziness = 3 }, "search fuzziness 3 is not between 0 and 2"},
+	}
+	for _, test := range tests {
+		c := Default()
+		test.change(c)
+		if err := c.Validate(); err == nil || err.Error() != test.err {
+			t.Errorf("got %v, want %q", err, test.err)
+		}
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "1pass", "config.toml")
+
+	c := Default()
+	c.Width = 800
+	c.LockTimeout.Duration = 90 * time.Second
+	c.Offline = true
+	c.Search.EquivalentDomains = [][]string{{"example.com", "example.org"}}
+	c.SecretService.Vaults = []string{"Secret Service", "Private"}
+
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Fatalf("got %+v, want %+v", got, c)
+	}
+
+	// Invalid settings aren't written.
+	c.Clipboard = "pbcopy"
+	if err := c.Save(path); err == nil {
+		t.Fatal("saved invalid settings")
+	}
+	if got, err := Load(path); err != nil || got.Clipboard != "xsel" {
+		t.Fatalf("got %v, %v after saving invalid settings", got, err)
+	}
+
+	// No temporary files are left.
+	entries, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d files, want 1", len(entries))
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "config.toml")
+
+	type result struct {
+		c   *Config
+		err error
+	}
+	results := make(chan result, 10)
+
+	watcher, err := Watch(path, func(c *Config, err error) {
+		results <- result{c, err}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	// next returns the config of the next reload.
+	next := func() result {
+		select {
+		case r := <-results:
+			return r
+		case <-time.After(5 * time.Second):
+			t.Fatal("config not reloaded")
+			return result{}
+		}
+	}
+
+	// Other files in the directory are ignored.
+	if err := ioutil.WriteFile(filepath.Join(dir, "other.toml"), []byte("width = 1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Save replaces the file like an editor.
+	c := Default()
+	c.Width = 800
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if r := next(); r.err != nil || r.c.Width != 800 {
+		t.Fatalf("got %+v, %v, want width 800", r.c, r.err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("widht = 800\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		r := next()
+		if r.err != nil {
+			if !strings.Contains(r.err.Error(), "unknown setting") {
+				t.Fatalf("got error %v", r.err)
+			}
+			break
+		}
+	}
+
+	// Nothing is reloaded after closing.
+	if err := watcher.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for len(results) > 0 {
+		<-results
+	}
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case r := <-results:
+		t.Fatalf("reloaded %+v, %v after closing", r.c, r.err)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
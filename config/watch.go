@@ -0,0 +1,49 @@
+package config
+
+import (
+	"io"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+)
+
+// Watch calls f with the reloaded config, or the error reading it, whenever the file at path
+// changes until the returned watcher is closed. The config isn't validated, see Load. The directory is watched since editors
+// often replace the file instead of writing to it.
+func Watch(path string, f func(*Config, error)) (io.Closer, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "create watcher")
+	}
+
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, errors.Wrap(err, "watch config directory")
+	}
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != filepath.Clean(path) {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+					continue
+				}
+				f(Load(path))
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				f(nil, errors.Wrap(err, "watch config"))
+			}
+		}
+	}()
+
+	return watcher, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"time"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/golang-ui/nuklear/nk"
+)
+
+// confirmTimeout is how long a confirmation waits for an answer before it is denied.
+const confirmTimeout = time.Minute
+
+// confirmRequest is a question waiting to be allowed or denied by the user.
+type confirmRequest struct {
+	text  string
+	reply chan bool
+}
+
+// confirm shows the text in the window and blocks until the user allows or denies it. The
+// request is denied if not answered within the timeout. It must not be called from the main
+// thread.
+func (s *UIState) confirm(window *glfw.Window, text string) bool {
+	req := &confirmRequest{
+		text:  text,
+		reply: make(chan bool, 1),
+	}
+
+	s.queue(func() {
+		s.confirms = append(s.confirms, req)
+		window.Show()
+	})
+
+	select {
+	case allow := <-req.reply:
+		return allow
+	case <-time.After(confirmTimeout):
+		s.queue(func() {
+			s.answerConfirm(req, false)
+		})
+		return false
+	}
+}
+
+// answerConfirm replies to the request and removes it from the pending requests.
+func (s *UIState) answerConfirm(req *confirmRequest, allow bool) {
+	for i, r := range s.confirms {
+		if r == req {
+			s.confirms = append(s.confirms[:i], s.confirms[i+1:]...)
+			req.reply <- allow
+			return
+		}
+	}
+}
+
+// Confirm draws the oldest pending confirmation.
+func Confirm(window *glfw.Window, ctx *nk.Context, state *UIState) {
+	req := state.confirms[0]
+
+	width, height := window.GetSize()
+	bounds := nk.NkRect(0, 0, float32(width), float32(height))
+	if nk.NkBegin(ctx, "confirm", bounds, nk.WindowNoScrollbar) > 0 {
+		nk.NkLayoutRowDynamic(ctx, 80, 1)
+		nk.NkLabelWrap(ctx, req.text)
+
+		nk.NkLayoutRowDynamic(ctx, 30, 2)
+		if nk.NkButtonLabel(ctx, "Deny") > 0 {
+			state.answerConfirm(req, false)
+		}
+		if nk.NkButtonLabel(ctx, "Allow") > 0 {
+			state.answerConfirm(req, true)
+		}
+
+		nk.NkEnd(ctx)
+	}
+}
@@ -0,0 +1,90 @@
+// Package dbustest runs a private D-Bus session bus for tests, so they don't need the bus of
+// the desktop and can't interfere with the services on it.
+package dbustest
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/godbus/dbus"
+	errors2 "github.com/pkg/errors"
+)
+
+// ErrNotInstalled is returned by Start when dbus-daemon isn't installed. Tests skip then.
+var ErrNotInstalled = errors.New("dbus-daemon is not installed")
+
+// Bus is a dbus-daemon started for a test.
+type Bus struct {
+	// Address is the address of the bus.
+	Address string
+
+	cmd  *exec.Cmd
+	prev string // DBUS_SESSION_BUS_ADDRESS before the bus was started
+}
+
+// Start starts a session bus and sets DBUS_SESSION_BUS_ADDRESS to it, so code connecting to
+// the session bus connects to it. Close must be called to stop it.
+func Start() (*Bus, error) {
+	path, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		return nil, errors2.WithStack(ErrNotInstalled)
+	}
+
+	cmd := exec.Command(path, "--session", "--nofork", "--nopidfile", "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors2.Wrap(err, "open stdout pipe")
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errors2.Wrap(err, "start dbus-daemon")
+	}
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, errors2.Wrap(err, "read bus address")
+	}
+
+	b := &Bus{
+		Address: strings.TrimSpace(address),
+		cmd:     cmd,
+		prev:    os.Getenv("DBUS_SESSION_BUS_ADDRESS"),
+	}
+	os.Setenv("DBUS_SESSION_BUS_ADDRESS", b.Address)
+
+	return b, nil
+}
+
+// Conn returns a new connection to the bus, the caller closes it.
+func (b *Bus) Conn() (*dbus.Conn, error) {
+	conn, err := dbus.Dial(b.Address)
+	if err != nil {
+		return nil, errors2.Wrap(err, "connect to bus")
+	}
+	if err := conn.Auth(nil); err != nil {
+		conn.Close()
+		return nil, errors2.Wrap(err, "authenticate")
+	}
+	if err := conn.Hello(); err != nil {
+		conn.Close()
+		return nil, errors2.Wrap(err, "hello")
+	}
+	return conn, nil
+}
+
+// Close stops the bus and restores DBUS_SESSION_BUS_ADDRESS.
+func (b *Bus) Close() error {
+	if b.prev == "" {
+		os.Unsetenv("DBUS_SESSION_BUS_ADDRESS")
+	} else {
+		os.Setenv("DBUS_SESSION_BUS_ADDRESS", b.prev)
+	}
+
+	b.cmd.Process.Kill()
+	b.cmd.Wait()
+	return nil
+}
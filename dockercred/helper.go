@@ -0,0 +1,257 @@
+package dockercred
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/michalnicp/1pass/op"
+	errors2 "github.com/pkg/errors"
+)
+
+// Tag is added to the items created by the helper. Only tagged items are listed, replaced or
+// erased.
+const Tag = "docker-credential-1pass"
+
+var (
+
+	// ErrCredentialsNotFound is returned when no item matches the server url. The message is
+	// the one docker expects from a credential helper.
+	ErrCredentialsNotFound = errors.New("credentials not found in native keychain")
+
+	// ErrMissingServerURL is returned when the server url is empty.
+	ErrMissingServerURL = errors.New("no credentials server URL")
+)
+
+// Credentials are the registry credentials exchanged with docker.
+type Credentials struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// Helper implements the docker credential helper protocol using 1Password login items, see
+// https://github.com/docker/docker-credential-helpers.
+type Helper struct {
+	Session *op.Session
+
+	// Vault is the vault new items are stored in. The default vault is used if empty.
+	Vault string
+
+	// Reveal is called with every item whose secret is returned if set.
+	Reveal func(item *op.Item)
+}
+
+// Run performs the action reading the request from r and writing the response to w.
+func (h *Helper) Run(action string, r io.Reader, w io.Writer) error {
+	switch action {
+	case "store":
+		var creds Credentials
+		if err := json.NewDecoder(r).Decode(&creds); err != nil {
+			return errors2.Wrap(err, "decode credentials")
+		}
+		return h.Store(&creds)
+	case "get":
+		serverURL, err := readServerURL(r)
+		if err != nil {
+			return err
+		}
+		creds, err := h.Get(serverURL)
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(w).Encode(creds)
+	case "erase":
+		serverURL, err := readServerURL(r)
+		if err != nil {
+			return err
+		}
+		return h.Erase(serverURL)
+	case "list":
+		creds, err := h.List()
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(w).Encode(creds)
+	}
+
+	return errors2.Errorf("unknown action %q", action)
+}
+
+// Get returns the credentials of the most recently updated login item matching the server
+// url.
+func (h *Helper) Get(serverURL string) (*Credentials, error) {
+	items, err := h.match(serverURL)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		item, err := h.Session.GetItem(item.UUID)
+		if err != nil {
+			return nil, errors2.Wrap(err, "get item")
+		}
+		if item.Details == nil || item.Details.Value("password") == "" {
+			continue
+		}
+
+		creds := Credentials{
+			ServerURL: serverURL,
+			Username:  item.Details.Value("username"),
+			Secret:    item.Details.Value("password"),
+		}
+		if h.Reveal != nil {
+			h.Reveal(item)
+		}
+		return &creds, nil
+	}
+
+	return nil, ErrCredentialsNotFound
+}
+
+// Store creates a login item for the credentials replacing the ones previously stored for
+// the server url. The previous items are deleted after the new one is created, so they are
+// kept if creating fails.
+func (h *Helper) Store(creds *Credentials) error {
+	u, err := parseServerURL(creds.ServerURL)
+	if err != nil {
+		return err
+	}
+
+	previous, err := h.match(creds.ServerURL)
+	if err != nil {
+		return err
+	}
+
+	item := op.Item{
+		Details: &op.Details{
+			Fields: []op.DetailsField{
+				{Designation: "username", Name: "username", Type: "T", Value: creds.Username},
+				{Designation: "password", Name: "password", Type: "P", Value: creds.Secret},
+			},
+		},
+	}
+	item.Overview.Title = creds.ServerURL
+	item.Overview.URL = u.String()
+	item.Overview.Tags = []string{Tag}
+
+	if _, err := h.Session.CreateItem("Login", h.Vault, &item); err != nil {
+		return errors2.Wrap(err, "create item")
+	}
+
+	_, err = h.delete(previous)
+	return err
+}
+
+// Erase deletes the items stored by the helper for the server url.
+func (h *Helper) Erase(serverURL string) error {
+	items, err := h.match(serverURL)
+	if err != nil {
+		return err
+	}
+
+	erased, err := h.delete(items)
+	if err != nil {
+		return err
+	}
+	if !erased {
+		return ErrCredentialsNotFound
+	}
+
+	return nil
+}
+
+// delete deletes the items stored by the helper and reports whether there were any.
+func (h *Helper) delete(items []op.Item) (bool, error) {
+	var deleted bool
+	for _, item := range items {
+		if !item.HasTag(Tag) {
+			continue
+		}
+
+		if err := h.Session.DeleteItem(item.UUID); err != nil {
+			return deleted, errors2.Wrap(err, "delete item")
+		}
+		deleted = true
+	}
+	return deleted, nil
+}
+
+// List returns the usernames of the items stored by the helper keyed by server url.
+func (h *Helper) List() (map[string]string, error) {
+	items, err := h.Session.ListItems()
+	if err != nil {
+		return nil, errors2.Wrap(err, "list items")
+	}
+
+	creds := make(map[string]string)
+	for _, item := range items {
+		if item.TemplateUUID == op.TemplateLogin && item.HasTag(Tag) {
+			creds[item.Overview.Title] = item.Overview.AInfo
+		}
+	}
+
+	return creds, nil
+}
+
+// match returns the login items for the host of the server url, most recently updated first.
+func (h *Helper) match(serverURL string) ([]op.Item, error) {
+	u, err := parseServerURL(serverURL)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := h.Session.MatchURL(u.String())
+	if err != nil {
+		return nil, errors2.Wrap(err, "match url")
+	}
+
+	var items []op.Item
+	for _, match := range matches {
+		if match.Item.TemplateUUID == op.TemplateLogin && match.Rank >= op.MatchHost {
+			items = append(items, match.Item)
+		}
+	}
+
+	return items, nil
+}
+
+// readServerURL reads the server url sent by docker for the get and erase actions.
+func readServerURL(r io.Reader) (string, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", errors2.Wrap(err, "read server url")
+	}
+
+	serverURL := strings.TrimSpace(string(b))
+	if serverURL == "" {
+		return "", ErrMissingServerURL
+	}
+
+	return serverURL, nil
+}
+
+// parseServerURL parses a registry server url. Registries are often given as a bare host,
+// eg. gcr.io, in which case https is assumed.
+func parseServerURL(serverURL string) (*url.URL, error) {
+	if serverURL == "" {
+		return nil, ErrMissingServerURL
+	}
+
+	if !strings.Contains(serverURL, "://") {
+		serverURL = "https://" + serverURL
+	}
+
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, errors2.Wrap(err, "parse server url")
+	}
+	if u.Host == "" {
+		return nil, errors2.Errorf("invalid server url %q", serverURL)
+	}
+
+	return u, nil
+}
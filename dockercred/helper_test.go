@@ -0,0 +1,157 @@
+package dockercred
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/michalnicp/1pass/op"
+	"github.com/michalnicp/1pass/op/optest"
+)
+
+func TestMain(m *testing.M) {
+	optest.Main(m)
+}
+
+func TestParseServerURL(t *testing.T) {
+	tests := []struct {
+		serverURL string
+		want      string
+	}{
+		{"https://index.docker.io/v1/", "https://index.docker.io/v1/"},
+		{"gcr.io", "https://gcr.io"},
+		{"registry.example.com:5000", "https://registry.example.com:5000"},
+		{"http://localhost:5000", "http://localhost:5000"},
+	}
+	for _, test := range tests {
+		u, err := parseServerURL(test.serverURL)
+		if err != nil {
+			t.Errorf("%s: %v", test.serverURL, err)
+			continue
+		}
+		if u.String() != test.want {
+			t.Errorf("%s: got %s, want %s", test.serverURL, u, test.want)
+		}
+	}
+
+	for _, serverURL := range []string{"", "https://"} {
+		if _, err := parseServerURL(serverURL); err == nil {
+			t.Errorf("%q: expected an error", serverURL)
+		}
+	}
+}
+
+// TestRun runs the actions docker sends in order against the fake op.
+func TestRun(t *testing.T) {
+	// A login created by hand is returned but never replaced or erased.
+	manual := op.Item{TemplateUUID: op.TemplateLogin, Details: &op.Details{Fields: []op.DetailsField{
+		{Designation: "username", Value: "manual"},
+		{Designation: "password", Value: "manual-secret"},
+	}}}
+	manual.Overview.Title = "registry.example.com"
+	manual.Overview.URL = "https://registry.example.com"
+	manual.Overview.AInfo = "manual"
+
+	fake, err := optest.New(nil, []op.Item{manual}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	session, err := fake.Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Helper{Session: session}
+
+	tests := []struct {
+		action string
+		input  string
+		want   string
+		err    string
+	}{
+		{"list", "", `{}`, ""},
+		{"store", `{"ServerURL":"https://index.docker.io/v1/","Username":"user","Secret":"first"}`, "", ""},
+		{"get", "https://index.docker.io/v1/\n", `{"ServerURL":"https://index.docker.io/v1/","Username":"user","Secret":"first"}`, ""},
+
+		// Storing again replaces the item.
+		{"store", `{"ServerURL":"https://index.docker.io/v1/","Username":"user","Secret":"second"}`, "", ""},
+		{"list", "", `{"https://index.docker.io/v1/":"user"}`, ""},
+
+		// A bare host is https.
+		{"get", "index.docker.io", `{"ServerURL":"index.docker.io","Username":"user","Secret":"second"}`, ""},
+		{"get", "registry.example.com", `{"ServerURL":"registry.example.com","Username":"manual","Secret":"manual-secret"}`, ""},
+		{"get", "gcr.io", "", ErrCredentialsNotFound.Error()},
+		{"get", "", "", ErrMissingServerURL.Error()},
+
+		{"erase", "registry.example.com", "", ErrCredentialsNotFound.Error()},
+		{"erase", "https://index.docker.io/v1/", "", ""},
+		{"get", "https://index.docker.io/v1/", "", ErrCredentialsNotFound.Error()},
+		{"list", "", `{}`, ""},
+
+		{"store", `not json`, "", "decode credentials"},
+		{"delete", "", "", `unknown action "delete"`},
+	}
+	for i, test := range tests {
+		var out bytes.Buffer
+		err := h.Run(test.action, strings.NewReader(test.input), &out)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("%d %s: got error %v, want %q", i, test.action, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d %s: %v", i, test.action, err)
+		}
+		if got := strings.TrimSpace(out.String()); got != test.want {
+			t.Fatalf("%d %s: got %s, want %s", i, test.action, got, test.want)
+		}
+	}
+
+	items, err := fake.Items()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Overview.Title != "registry.example.com" {
+		t.Fatalf("got items %+v, want only the manual login", items)
+	}
+}
+
+// TestStoreFailed checks the previous credentials are kept when the new ones can't be stored.
+func TestStoreFailed(t *testing.T) {
+	fake, err := optest.New(nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	session, err := fake.Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := Helper{Session: session}
+	if err := h.Store(&Credentials{ServerURL: "gcr.io", Username: "user", Secret: "first"}); err != nil {
+		t.Fatal(err)
+	}
+
+	h.Vault = "missing"
+	if err := h.Store(&Credentials{ServerURL: "gcr.io", Username: "user", Secret: "second"}); err == nil {
+		t.Fatal("expected an error storing in a missing vault")
+	}
+
+	var revealed int
+	h.Reveal = func(item *op.Item) { revealed++ }
+
+	creds, err := h.Get("gcr.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.Secret != "first" {
+		t.Fatalf("got secret %q, want the previous one", creds.Secret)
+	}
+	if revealed != 1 {
+		t.Fatalf("revealed %d items, want 1", revealed)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/michalnicp/1pass/dockercred"
+	"github.com/michalnicp/1pass/logging"
+	"github.com/michalnicp/1pass/op"
+)
+
+// dockerCredential runs the docker credential helper. Configure docker to use it by setting
+// "credsStore": "1pass" in ~/.docker/config.json.
+func dockerCredential(args []string) error {
+	flags := flag.NewFlagSet("docker-credential", flag.ContinueOnError)
+	vault := flags.String("vault", "", "vault to store new credentials in")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	session, err := cliSession()
+	if err != nil {
+		return err
+	}
+
+	openAuditLog()
+	defer auditLog.Close()
+
+	helper := dockercred.Helper{
+		Session: session,
+		Vault:   *vault,
+		Reveal: func(item *op.Item) {
+			recordAccess(logging.ActionReveal, item, "password", "docker-credential")
+		},
+	}
+
+	if err := helper.Run(flags.Arg(0), os.Stdin, os.Stdout); err != nil {
+
+		// Docker reads the error message from stdout.
+		fmt.Fprintln(os.Stdout, err)
+		return err
+	}
+
+	return nil
+}
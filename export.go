@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/michalnicp/1pass/export"
+	"github.com/michalnicp/1pass/logging"
+	"github.com/michalnicp/1pass/op"
+	"github.com/pkg/errors"
+)
+
+// exportItems writes every item as a KeePass 2 XML file, Bitwarden json export or csv.
+//
+//  1pass export --format keepass -o 1password.xml
+//
+// The output is unencrypted, it is only readable by the owner when written to a file.
+func exportItems(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", "keepass", "output format, keepass, bitwarden or csv")
+	vault := flags.String("vault", "", "only export the items in the vault")
+	interval := flags.Duration("interval", 200*time.Millisecond, "minimum time between fetching items")
+	out := flags.String("o", "", "output file, written to stdout if empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	session, err := cliSession()
+	if err != nil {
+		return err
+	}
+
+	vaults, err := session.ListVaults()
+	if err != nil {
+		return errors.Wrap(err, "list vaults")
+	}
+
+	items, err := session.ListItems()
+	if err != nil {
+		return errors.Wrap(err, "list items")
+	}
+
+	if *vault != "" {
+		var filtered []op.Item
+		for _, item := range items {
+			for _, v := range vaults {
+				if v.UUID == item.VaultUUID && (v.UUID == *vault || strings.EqualFold(v.Name, *vault)) {
+					filtered = append(filtered, item)
+				}
+			}
+		}
+		items = filtered
+	}
+
+	details, err := session.GetItems(items, *interval)
+	if err != nil {
+		return errors.Wrap(err, "get items")
+	}
+
+	openAuditLog()
+	defer auditLog.Close()
+	for _, item := range details {
+		recordAccess(logging.ActionReveal, item, "all fields", "export")
+	}
+
+	entries := export.NewEntries(details, vaults)
+
+	var buf bytes.Buffer
+	switch *format {
+	case "keepass":
+		err = export.WriteKeePass(&buf, entries)
+	case "bitwarden":
+		err = export.WriteBitwarden(&buf, entries)
+	case "csv":
+		err = export.WriteCSV(&buf, entries)
+	default:
+		return errors.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		return errors.Wrap(err, "write "+*format)
+	}
+
+	if *out == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+
+	return writeFileSecure(*out, buf.Bytes())
+}
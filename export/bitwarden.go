@@ -0,0 +1,121 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Bitwarden item and field types.
+const (
+	BitwardenLogin      = 1
+	BitwardenSecureNote = 2
+
+	BitwardenFieldText   = 0
+	BitwardenFieldHidden = 1
+)
+
+// Bitwarden unencrypted json export types.
+type (
+	BitwardenExport struct {
+		Encrypted bool              `json:"encrypted"`
+		Folders   []BitwardenFolder `json:"folders"`
+		Items     []BitwardenItem   `json:"items"`
+	}
+
+	BitwardenFolder struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+
+	BitwardenItem struct {
+		ID         string                   `json:"id"`
+		FolderID   string                   `json:"folderId,omitempty"`
+		Type       int                      `json:"type"`
+		Name       string                   `json:"name"`
+		Notes      string                   `json:"notes,omitempty"`
+		Favorite   bool                     `json:"favorite"`
+		Fields     []BitwardenField         `json:"fields,omitempty"`
+		Login      *BitwardenLoginData      `json:"login,omitempty"`
+		SecureNote *BitwardenSecureNoteData `json:"secureNote,omitempty"`
+	}
+
+	BitwardenField struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+		Type  int    `json:"type"`
+	}
+
+	BitwardenLoginData struct {
+		Username string         `json:"username,omitempty"`
+		Password string         `json:"password,omitempty"`
+		TOTP     string         `json:"totp,omitempty"`
+		URIs     []BitwardenURI `json:"uris,omitempty"`
+	}
+
+	BitwardenURI struct {
+		URI string `json:"uri"`
+	}
+
+	BitwardenSecureNoteData struct {
+		Type int `json:"type"`
+	}
+)
+
+// WriteBitwarden writes the entries as an unencrypted Bitwarden json export. Each vault
+// becomes a folder and section fields become custom fields named "section: field".
+func WriteBitwarden(w io.Writer, entries []Entry) error {
+	export := BitwardenExport{}
+
+	folders := make(map[string]string)
+	for _, entry := range entries {
+		if _, ok := folders[entry.Vault]; !ok && entry.Vault != "" {
+			folders[entry.Vault] = fmt.Sprintf("%x", entryUUID("vault:"+entry.Vault))
+		}
+	}
+	for name, id := range folders {
+		export.Folders = append(export.Folders, BitwardenFolder{ID: id, Name: name})
+	}
+	sort.Slice(export.Folders, func(i, j int) bool {
+		return export.Folders[i].Name < export.Folders[j].Name
+	})
+
+	for _, entry := range entries {
+		item := BitwardenItem{
+			ID:       fmt.Sprintf("%x", entryUUID(entry.UUID)),
+			FolderID: folders[entry.Vault],
+			Name:     entry.Title,
+			Notes:    entry.Notes,
+		}
+
+		if entry.Username != "" || entry.Password != "" || entry.TOTP != "" || len(entry.URLs) > 0 {
+			item.Type = BitwardenLogin
+			item.Login = &BitwardenLoginData{
+				Username: entry.Username,
+				Password: entry.Password,
+				TOTP:     entry.TOTP,
+			}
+			for _, u := range entry.URLs {
+				item.Login.URIs = append(item.Login.URIs, BitwardenURI{URI: u})
+			}
+		} else {
+			item.Type = BitwardenSecureNote
+			item.SecureNote = &BitwardenSecureNoteData{}
+		}
+
+		for _, field := range entry.Fields {
+			typ := BitwardenFieldText
+			if field.Concealed {
+				typ = BitwardenFieldHidden
+			}
+			item.Fields = append(item.Fields, BitwardenField{Name: FieldKey(field), Value: field.Value, Type: typ})
+		}
+
+		export.Items = append(export.Items, item)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(export)
+}
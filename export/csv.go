@@ -0,0 +1,53 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// CSVHeader are the columns written by WriteCSV.
+var CSVHeader = []string{"title", "vault", "username", "password", "url", "urls", "totp", "notes", "tags", "fields"}
+
+// WriteCSV writes the entries as csv with a header row. Additional urls are separated by
+// newlines. Custom fields are written one per line as "section: field=value".
+func WriteCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(CSVHeader); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		var url string
+		var urls []string
+		if len(entry.URLs) > 0 {
+			url = entry.URLs[0]
+			urls = entry.URLs[1:]
+		}
+
+		var fields []string
+		for _, field := range entry.Fields {
+			fields = append(fields, FieldKey(field)+"="+field.Value)
+		}
+
+		record := []string{
+			entry.Title,
+			entry.Vault,
+			entry.Username,
+			entry.Password,
+			url,
+			strings.Join(urls, "\n"),
+			entry.TOTP,
+			entry.Notes,
+			strings.Join(entry.Tags, ","),
+			strings.Join(fields, "\n"),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
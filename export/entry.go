@@ -0,0 +1,89 @@
+package export
+
+import (
+	"crypto/md5"
+	"strings"
+	"time"
+
+	"github.com/michalnicp/1pass/op"
+)
+
+// Entry is an item normalized for the export formats.
+type Entry struct {
+	UUID      string
+	Vault     string
+	Title     string
+	Username  string
+	Password  string
+	URLs      []string
+	TOTP      string // otpauth:// uri
+	Notes     string
+	Tags      []string
+	Fields    []Field // fields other than the above, including section fields
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// NewEntry normalizes an item with details from the vault.
+func NewEntry(item *op.Item, vault string) Entry {
+	entry := Entry{
+		UUID:      item.UUID,
+		Vault:     vault,
+		Title:     item.Overview.Title,
+		Tags:      item.Overview.Tags,
+		CreatedAt: item.CreatedAt,
+		UpdatedAt: item.UpdatedAt,
+	}
+
+	entry.URLs = item.URLs()
+
+	if item.Details == nil {
+		return entry
+	}
+	entry.Notes = item.Details.Notes
+	entry.Password = item.Details.Password
+
+	for _, field := range Fields(item) {
+		switch {
+		case field.Section == "" && field.Name == "username" && entry.Username == "":
+			entry.Username = field.Value
+		case field.Section == "" && field.Name == "password" && entry.Password == "":
+			entry.Password = field.Value
+		case field.Section == "" && field.Name == "notes":
+		case strings.HasPrefix(field.Value, "otpauth://") && entry.TOTP == "":
+			entry.TOTP = field.Value
+		default:
+			entry.Fields = append(entry.Fields, field)
+		}
+	}
+
+	return entry
+}
+
+// NewEntries normalizes the items, vaults are named using the vault list.
+func NewEntries(items []*op.Item, vaults []op.Vault) []Entry {
+	names := make(map[string]string)
+	for _, vault := range vaults {
+		names[vault.UUID] = vault.Name
+	}
+
+	entries := make([]Entry, len(items))
+	for i, item := range items {
+		entries[i] = NewEntry(item, names[item.VaultUUID])
+	}
+	return entries
+}
+
+// FieldKey returns the name of a custom field, prefixed by its section.
+func FieldKey(field Field) string {
+	if field.Section == "" {
+		return field.Name
+	}
+	return field.Section + ": " + field.Name
+}
+
+// entryUUID returns a stable 16 byte uuid for the entry.
+func entryUUID(s string) []byte {
+	sum := md5.Sum([]byte(s))
+	return sum[:]
+}
@@ -0,0 +1,204 @@
+package export_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michalnicp/1pass/export"
+	"github.com/michalnicp/1pass/importer"
+	"github.com/michalnicp/1pass/op"
+)
+
+// fixtures returns a login with urls, a one time password, notes and custom fields, and a
+// secure note.
+func fixtures() ([]*op.Item, []op.Vault) {
+	login := &op.Item{
+		UUID:         "login",
+		TemplateUUID: op.TemplateLogin,
+		VaultUUID:    "vault",
+		CreatedAt:    time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC),
+		UpdatedAt:    time.Date(2018, 6, 7, 8, 9, 10, 0, time.UTC),
+		Details: &op.Details{
+			Fields: []op.DetailsField{
+				{Designation: "username", Name: "username", Type: "T", Value: "user@example.com"},
+				{Designation: "password", Name: "password", Type: "P", Value: "pass\"<word>&"},
+			},
+			Notes: "line one\nline two",
+			Sections: []op.Section{
+				{Title: "Server", Fields: []op.SectionField{
+					{Type: "string", Title: "host", Value: "db.example.com"},
+					{Type: "concealed", Title: "api key", Value: "key-123"},
+				}},
+				{Title: "", Fields: []op.SectionField{
+					{Type: "concealed", Title: "one-time password", Value: "otpauth://totp/Example?secret=JBSWY3DPEHPK3PXP"},
+				}},
+			},
+		},
+	}
+	login.Overview.Title = "Example"
+	login.Overview.URL = "https://example.com/login"
+	login.Overview.URLs = []op.ItemURL{{URL: "https://example.com/login"}, {URL: "https://m.example.com"}}
+	login.Overview.Tags = []string{"work", "web"}
+
+	note := &op.Item{
+		UUID:         "note",
+		TemplateUUID: "003",
+		VaultUUID:    "vault",
+		Details:      &op.Details{Notes: "just a note"},
+	}
+	note.Overview.Title = "Note"
+
+	return []*op.Item{login, note}, []op.Vault{{UUID: "vault", Name: "Private"}}
+}
+
+func TestRoundTrip(t *testing.T) {
+	items, vaults := fixtures()
+	entries := export.NewEntries(items, vaults)
+
+	writers := map[string]func(*bytes.Buffer, []export.Entry) error{
+		"keepass":   func(b *bytes.Buffer, e []export.Entry) error { return export.WriteKeePass(b, e) },
+		"bitwarden": func(b *bytes.Buffer, e []export.Entry) error { return export.WriteBitwarden(b, e) },
+		"csv":       func(b *bytes.Buffer, e []export.Entry) error { return export.WriteCSV(b, e) },
+	}
+
+	for format, write := range writers {
+		var b bytes.Buffer
+		if err := write(&b, entries); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		records, err := importer.Read(format, &b)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if len(records) != len(entries) {
+			t.Fatalf("%s: got %d records, want %d", format, len(records), len(entries))
+		}
+
+		for i, record := range records {
+			got := export.NewEntry(&record.Item, entries[i].Vault)
+			want := entries[i]
+
+			if got.Title != want.Title || got.Username != want.Username || got.Password != want.Password ||
+				got.TOTP != want.TOTP || got.Notes != want.Notes {
+				t.Errorf("%s: got %+v, want %+v", format, got, want)
+			}
+			if !reflect.DeepEqual(got.URLs, want.URLs) {
+				t.Errorf("%s %s: got urls %q, want %q", format, want.Title, got.URLs, want.URLs)
+			}
+			if format == "keepass" && !reflect.DeepEqual(got.Tags, append([]string{"Private"}, want.Tags...)) {
+				t.Errorf("%s %s: got tags %q", format, want.Title, got.Tags)
+			}
+
+			// Csv has no way to mark a field concealed.
+			concealed := format != "csv"
+			if got, want := customFields(got, concealed), customFields(want, concealed); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s %s: got fields %q, want %q", format, entries[i].Title, got, want)
+			}
+		}
+	}
+}
+
+// customFields returns the custom fields as "section: name=value", except the additional
+// urls the importer keeps in a section. Concealed fields are marked with a * if concealed is
+// set.
+func customFields(entry export.Entry, concealed bool) []string {
+	var fields []string
+	for _, field := range entry.Fields {
+		if field.Section == "URLs" {
+			continue
+		}
+		s := export.FieldKey(field) + "=" + field.Value
+		if concealed && field.Concealed {
+			s += " *"
+		}
+		fields = append(fields, s)
+	}
+	return fields
+}
+
+func TestKeePassDuplicateKeys(t *testing.T) {
+	entry := export.Entry{
+		UUID:     "entry",
+		Title:    "Example",
+		Username: "user",
+		Password: "secret",
+		Fields: []export.Field{
+			{Name: "Title", Value: "custom title"},
+			{Name: "Password", Value: "other", Concealed: true},
+			{Name: "pin", Value: "1"},
+			{Name: "pin", Value: "2"},
+			{Name: "pin (2)", Value: "3"},
+		},
+	}
+
+	var b bytes.Buffer
+	if err := export.WriteKeePass(&b, []export.Entry{entry}); err != nil {
+		t.Fatal(err)
+	}
+
+	var file export.KeePassFile
+	if err := xml.Unmarshal(b.Bytes(), &file); err != nil {
+		t.Fatal(err)
+	}
+
+	values := make(map[string]string)
+	for _, s := range file.Root.Groups[0].Entries[0].Strings {
+		if _, ok := values[s.Key]; ok {
+			t.Fatalf("duplicate key %q", s.Key)
+		}
+		values[s.Key] = s.Value.Value
+	}
+
+	want := map[string]string{
+		"Title":        "Example",
+		"Password":     "secret",
+		"Title (2)":    "custom title",
+		"Password (2)": "other",
+		"pin":          "1",
+		"pin (2)":      "2",
+		"pin (2) (2)":  "3",
+	}
+	for key, value := range want {
+		if values[key] != value {
+			t.Errorf("%q: got %q, want %q", key, values[key], value)
+		}
+	}
+}
+
+func TestKeysUnique(t *testing.T) {
+	fields := []export.Field{
+		{Name: "a"},
+		{Name: "a"},
+		{Name: "a_2"},
+		{Name: "a"},
+		{Section: "s", Name: "b"},
+		{Name: "s.b"},
+	}
+
+	keys := export.Keys(fields, ".", func(r rune) bool { return r != ' ' })
+	want := []string{"a", "a_2", "a_2_2", "a_3", "s.b", "s.b_2"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("got %q, want %q", keys, want)
+	}
+
+	var b bytes.Buffer
+	if err := export.WriteDotenv(&b, []export.Field{{Name: "1x"}, {Name: "_1x"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != "_1X=\"\"\n_1X_2=\"\"\n" {
+		t.Fatalf("got %q", got)
+	}
+
+	b.Reset()
+	if err := export.WriteDotenv(&b, []export.Field{{Name: "api key"}, {Name: "API_KEY"}}); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(b.String()), "\n"); lines[0][:8] != "API_KEY=" || lines[1][:10] != "API_KEY_2=" {
+		t.Fatalf("got %q", b.String())
+	}
+}
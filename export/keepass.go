@@ -0,0 +1,159 @@
+package export
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeePass 2 XML types. Only the elements written by the export are defined.
+type (
+	KeePassFile struct {
+		XMLName xml.Name     `xml:"KeePassFile"`
+		Meta    KeePassMeta  `xml:"Meta"`
+		Root    KeePassGroup `xml:"Root>Group"`
+	}
+
+	KeePassMeta struct {
+		Generator    string `xml:"Generator"`
+		DatabaseName string `xml:"DatabaseName"`
+	}
+
+	KeePassGroup struct {
+		UUID    string         `xml:"UUID"`
+		Name    string         `xml:"Name"`
+		Entries []KeePassEntry `xml:"Entry"`
+		Groups  []KeePassGroup `xml:"Group"`
+	}
+
+	KeePassEntry struct {
+		UUID    string          `xml:"UUID"`
+		Tags    string          `xml:"Tags,omitempty"`
+		Times   KeePassTimes    `xml:"Times"`
+		Strings []KeePassString `xml:"String"`
+	}
+
+	KeePassTimes struct {
+		CreationTime         time.Time `xml:"CreationTime"`
+		LastModificationTime time.Time `xml:"LastModificationTime"`
+	}
+
+	KeePassString struct {
+		Key   string       `xml:"Key"`
+		Value KeePassValue `xml:"Value"`
+	}
+
+	KeePassValue struct {
+		Value           string `xml:",chardata"`
+		ProtectInMemory string `xml:"ProtectInMemory,attr,omitempty"`
+	}
+)
+
+// WriteKeePass writes the entries as an unencrypted KeePass 2 XML file, which KeePass and
+// KeePassXC can import. Each vault becomes a group. Additional urls are stored as KP2A_URL
+// strings and the totp uri as otp, as read by KeePassXC and KeePass2Android.
+func WriteKeePass(w io.Writer, entries []Entry) error {
+	file := KeePassFile{
+		Meta: KeePassMeta{
+			Generator:    "1pass",
+			DatabaseName: "1Password",
+		},
+		Root: KeePassGroup{
+			UUID: base64.StdEncoding.EncodeToString(entryUUID("root")),
+			Name: "1Password",
+		},
+	}
+
+	groups := make(map[string]*KeePassGroup)
+	var vaults []string
+	for _, entry := range entries {
+		group, ok := groups[entry.Vault]
+		if !ok {
+			group = &KeePassGroup{
+				UUID: base64.StdEncoding.EncodeToString(entryUUID("vault:" + entry.Vault)),
+				Name: entry.Vault,
+			}
+			groups[entry.Vault] = group
+			vaults = append(vaults, entry.Vault)
+		}
+		group.Entries = append(group.Entries, keePassEntry(entry))
+	}
+
+	sort.Strings(vaults)
+	for _, vault := range vaults {
+		file.Root.Groups = append(file.Root.Groups, *groups[vault])
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(file); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func keePassEntry(entry Entry) KeePassEntry {
+	e := KeePassEntry{
+		UUID: base64.StdEncoding.EncodeToString(entryUUID(entry.UUID)),
+		Tags: strings.Join(entry.Tags, ";"),
+		Times: KeePassTimes{
+			CreationTime:         entry.CreatedAt.UTC(),
+			LastModificationTime: entry.UpdatedAt.UTC(),
+		},
+	}
+
+	// KeePass drops or rejects entries with duplicate keys, custom fields named like a
+	// standard field or another custom field are numbered.
+	used := make(map[string]bool)
+	add := func(key, value string, protect bool) {
+		for n, base := 2, key; used[key]; n++ {
+			key = base + " (" + strconv.Itoa(n) + ")"
+		}
+		used[key] = true
+
+		s := KeePassString{Key: key, Value: KeePassValue{Value: value}}
+		if protect {
+			s.Value.ProtectInMemory = "True"
+		}
+		e.Strings = append(e.Strings, s)
+	}
+
+	add("Title", entry.Title, false)
+	add("UserName", entry.Username, false)
+	add("Password", entry.Password, true)
+
+	var url string
+	if len(entry.URLs) > 0 {
+		url = entry.URLs[0]
+	}
+	add("URL", url, false)
+	for i := 1; i < len(entry.URLs); i++ {
+		key := "KP2A_URL"
+		if i > 1 {
+			key += "_" + strconv.Itoa(i-1)
+		}
+		add(key, entry.URLs[i], false)
+	}
+
+	add("Notes", entry.Notes, false)
+
+	if entry.TOTP != "" {
+		add("otp", entry.TOTP, true)
+	}
+
+	for _, field := range entry.Fields {
+		add(FieldKey(field), field.Value, field.Concealed)
+	}
+
+	return e
+}
@@ -0,0 +1,206 @@
+package export
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/michalnicp/1pass/op"
+)
+
+// Field is an item field flattened for export.
+type Field struct {
+	Section   string // section title, empty for the item fields
+	Name      string
+	Value     string
+	Concealed bool
+}
+
+// Fields returns the fields of the item in order. Item fields are named by their designation,
+// eg. username, or their name. Section fields are named by their title. The notes are
+// included as a field named notes.
+func Fields(item *op.Item) []Field {
+	if item.Details == nil {
+		return nil
+	}
+
+	var fields []Field
+	for _, field := range item.Details.Fields {
+		name := field.Designation
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, Field{Name: name, Value: field.Value, Concealed: field.Type == "P"})
+	}
+
+	for _, section := range item.Details.Sections {
+		title := section.Title
+		if title == "" {
+			title = section.Name
+		}
+		for _, field := range section.Fields {
+			name := field.Title
+			if name == "" {
+				name = field.Name
+			}
+			fields = append(fields, Field{Section: title, Name: name, Value: field.Value, Concealed: field.Type == "concealed"})
+		}
+	}
+
+	if item.Details.Notes != "" {
+		fields = append(fields, Field{Name: "notes", Value: item.Details.Notes})
+	}
+
+	return fields
+}
+
+// Keys returns a key for each field made of the section and field name joined by sep, with
+// every character not accepted by valid replaced by an underscore. Keys are made unique by
+// appending a number.
+func Keys(fields []Field, sep string, valid func(r rune) bool) []string {
+	return uniqueKeys(fieldKeys(fields, sep, valid))
+}
+
+func fieldKeys(fields []Field, sep string, valid func(r rune) bool) []string {
+	keys := make([]string, len(fields))
+	for i, field := range fields {
+		keys[i] = sanitize(field.Name, valid)
+		if field.Section != "" {
+			keys[i] = sanitize(field.Section, valid) + sep + keys[i]
+		}
+	}
+	return keys
+}
+
+// uniqueKeys appends _2, _3 and so on to keys already used, including by the numbered keys.
+func uniqueKeys(keys []string) []string {
+	used := make(map[string]bool)
+	for i, key := range keys {
+		for n := 2; used[key]; n++ {
+			key = keys[i] + "_" + strconv.Itoa(n)
+		}
+		used[key] = true
+		keys[i] = key
+	}
+	return keys
+}
+
+// sanitize replaces runs of invalid characters with an underscore.
+func sanitize(s string, valid func(r rune) bool) string {
+	var b strings.Builder
+	var invalid bool
+	for _, r := range strings.TrimSpace(s) {
+		if valid(r) {
+			b.WriteRune(r)
+			invalid = false
+			continue
+		}
+		if !invalid {
+			b.WriteByte('_')
+		}
+		invalid = true
+	}
+
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// validSecretKey reports whether r is allowed in the key of a kubernetes secret.
+func validSecretKey(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.')
+}
+
+// validEnvKey reports whether r is allowed in an environment variable name.
+func validEnvKey(r rune) bool {
+	return (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_'
+}
+
+// WriteSecret writes the fields as a kubernetes Secret manifest. Section and field names are
+// joined with a dot.
+func WriteSecret(w io.Writer, name, namespace string, fields []Field) error {
+	keys := Keys(fields, ".", validSecretKey)
+
+	var b strings.Builder
+	b.WriteString("apiVersion: v1\n")
+	b.WriteString("kind: Secret\n")
+	b.WriteString("metadata:\n")
+	fmt.Fprintf(&b, "  name: %s\n", ResourceName(name))
+	if namespace != "" {
+		fmt.Fprintf(&b, "  namespace: %s\n", ResourceName(namespace))
+	}
+	b.WriteString("type: Opaque\n")
+	b.WriteString("data:\n")
+	for i, field := range fields {
+		fmt.Fprintf(&b, "  %s: %s\n", strconv.Quote(keys[i]), base64.StdEncoding.EncodeToString([]byte(field.Value)))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteDotenv writes the fields as KEY="value" lines. Names are upper cased and section and
+// field names are joined with an underscore.
+func WriteDotenv(w io.Writer, fields []Field) error {
+	upper := make([]Field, len(fields))
+	for i, field := range fields {
+		upper[i] = Field{
+			Section: strings.ToUpper(field.Section),
+			Name:    strings.ToUpper(field.Name),
+			Value:   field.Value,
+		}
+	}
+	keys := fieldKeys(upper, "_", validEnvKey)
+	for i, key := range keys {
+		if key[0] >= '0' && key[0] <= '9' {
+			keys[i] = "_" + key
+		}
+	}
+	keys = uniqueKeys(keys)
+
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`)
+
+	var b strings.Builder
+	for i, field := range fields {
+		fmt.Fprintf(&b, "%s=\"%s\"\n", keys[i], replacer.Replace(field.Value))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the fields as a flat json object. Section and field names are joined with
+// a dot.
+func WriteJSON(w io.Writer, fields []Field) error {
+	keys := Keys(fields, ".", func(r rune) bool { return r != '.' })
+
+	obj := make(map[string]string)
+	for i, field := range fields {
+		obj[keys[i]] = field.Value
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(obj)
+}
+
+// ResourceName converts s to a valid kubernetes resource name, lower case alphanumeric
+// characters and dashes.
+func ResourceName(s string) string {
+	name := sanitize(strings.ToLower(s), func(r rune) bool {
+		return (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')
+	})
+	name = strings.Trim(strings.Replace(name, "_", "-", -1), "-")
+	if name == "" {
+		return "secret"
+	}
+	if len(name) > 253 {
+		name = name[:253]
+	}
+	return name
+}
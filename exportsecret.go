@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+
+	"github.com/michalnicp/1pass/export"
+	"github.com/michalnicp/1pass/logging"
+	"github.com/pkg/errors"
+)
+
+// exportSecret writes the fields of an item as a kubernetes secret, dotenv file or json.
+//
+//  1pass export-secret Postgres --format k8s | kubectl apply -f -
+func exportSecret(args []string) error {
+	flags := flag.NewFlagSet("export-secret", flag.ContinueOnError)
+	format := flags.String("format", "k8s", "output format, k8s, dotenv or json")
+	name := flags.String("name", "", "name of the kubernetes secret, defaults to the item title")
+	namespace := flags.String("namespace", "", "namespace of the kubernetes secret")
+	out := flags.String("o", "", "output file, written to stdout if empty")
+
+	args, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) != 1 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	session, err := cliSession()
+	if err != nil {
+		return err
+	}
+
+	item, err := session.GetItem(args[0])
+	if err != nil {
+		return errors.Wrap(err, "get item")
+	}
+
+	fields := export.Fields(item)
+
+	openAuditLog()
+	defer auditLog.Close()
+	for _, field := range fields {
+		name := field.Name
+		if field.Section != "" {
+			name = field.Section + "/" + field.Name
+		}
+		recordAccess(logging.ActionReveal, item, name, "export-secret")
+	}
+
+	var buf bytes.Buffer
+	switch *format {
+	case "k8s":
+		if *name == "" {
+			*name = item.Overview.Title
+		}
+		err = export.WriteSecret(&buf, *name, *namespace, fields)
+	case "dotenv":
+		err = export.WriteDotenv(&buf, fields)
+	case "json":
+		err = export.WriteJSON(&buf, fields)
+	default:
+		return errors.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		return errors.Wrap(err, "write "+*format)
+	}
+
+	if *out == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+
+	return writeFileSecure(*out, buf.Bytes())
+}
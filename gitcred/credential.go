@@ -0,0 +1,109 @@
+package gitcred
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Credential is the set of attributes exchanged with git using the credential helper
+// protocol, see git-credential(1).
+type Credential struct {
+	Protocol string
+	Host     string
+	Path     string
+	Username string
+	Password string
+}
+
+// Read reads the attributes of a credential from r. Attributes are read one per line in the
+// form key=value until a blank line or the end of the input.
+func Read(r io.Reader) (*Credential, error) {
+	var c Credential
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			return nil, errors.Errorf("invalid attribute %q", line)
+		}
+		key, value := line[:i], line[i+1:]
+
+		switch key {
+		case "protocol":
+			c.Protocol = value
+		case "host":
+			c.Host = value
+		case "path":
+			c.Path = value
+		case "username":
+			c.Username = value
+		case "password":
+			c.Password = value
+		case "url":
+			u, err := url.Parse(value)
+			if err != nil {
+				return nil, errors.Wrap(err, "parse url")
+			}
+			c.Protocol = u.Scheme
+			c.Host = u.Host
+			c.Path = strings.TrimPrefix(u.Path, "/")
+			if u.User != nil {
+				c.Username = u.User.Username()
+				if password, ok := u.User.Password(); ok {
+					c.Password = password
+				}
+			}
+		}
+
+		// Unknown attributes are ignored as required by the protocol.
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "read attributes")
+	}
+
+	return &c, nil
+}
+
+// Write writes the non empty attributes of the credential to w.
+func (c *Credential) Write(w io.Writer) error {
+	attrs := []struct {
+		key, value string
+	}{
+		{"protocol", c.Protocol},
+		{"host", c.Host},
+		{"path", c.Path},
+		{"username", c.Username},
+		{"password", c.Password},
+	}
+
+	for _, attr := range attrs {
+		if attr.value == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", attr.key, attr.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// URL returns the credential protocol, host and path as a url. The username and password are
+// not included.
+func (c *Credential) URL() *url.URL {
+	return &url.URL{
+		Scheme: c.Protocol,
+		Host:   c.Host,
+		Path:   c.Path,
+	}
+}
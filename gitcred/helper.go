@@ -0,0 +1,212 @@
+package gitcred
+
+import (
+	"io"
+
+	"github.com/michalnicp/1pass/op"
+	"github.com/pkg/errors"
+)
+
+// Tag is added to the items created by the helper. Only tagged items are replaced or erased,
+// items created by hand are never modified.
+const Tag = "git-credential-1pass"
+
+// Helper implements the get, store and erase actions of a git credential helper using
+// 1Password login items.
+type Helper struct {
+	Session *op.Session
+
+	// Vault is the vault new items are stored in. The default vault is used if empty.
+	Vault string
+
+	// Reveal is called with every item whose password is returned if set.
+	Reveal func(item *op.Item)
+}
+
+// Run reads a credential from r, performs the action and writes the response to w. Unknown
+// actions are ignored.
+func (h *Helper) Run(action string, r io.Reader, w io.Writer) error {
+	c, err := Read(r)
+	if err != nil {
+		return errors.Wrap(err, "read credential")
+	}
+
+	switch action {
+	case "get":
+		found, err := h.Get(c)
+		if err != nil {
+			return err
+		}
+		if found == nil {
+			return nil
+		}
+		return found.Write(w)
+	case "store":
+		return h.Store(c)
+	case "erase":
+		return h.Erase(c)
+	}
+
+	return nil
+}
+
+// Get returns the credential with the username and password filled in from the best
+// matching item, or nil if no item matches.
+func (h *Helper) Get(c *Credential) (*Credential, error) {
+	items, err := h.match(c)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		item, err := h.Session.GetItem(item.UUID)
+		if err != nil {
+			return nil, errors.Wrap(err, "get item")
+		}
+		if item.Details == nil {
+			continue
+		}
+
+		username := item.Details.Value("username")
+		password := item.Details.Value("password")
+		if password == "" {
+			continue
+		}
+		if c.Username != "" && username != "" && username != c.Username {
+			continue
+		}
+
+		found := *c
+		if username != "" {
+			found.Username = username
+		}
+		found.Password = password
+
+		if h.Reveal != nil {
+			h.Reveal(item)
+		}
+
+		return &found, nil
+	}
+
+	return nil, nil
+}
+
+// Store creates a login item for the credential. Items previously stored by the helper for
+// the same url and username are replaced, since op can't edit existing items.
+func (h *Helper) Store(c *Credential) error {
+	if c.Host == "" || c.Password == "" {
+		return nil
+	}
+
+	items, err := h.match(c)
+	if err != nil {
+		return err
+	}
+
+	var previous []op.Item
+	for _, item := range items {
+		if !item.HasTag(Tag) {
+			continue
+		}
+
+		details, err := h.Session.GetItem(item.UUID)
+		if err != nil {
+			return errors.Wrap(err, "get item")
+		}
+		if details.Details != nil && details.Details.Value("password") == c.Password {
+
+			// Already stored.
+			return nil
+		}
+
+		previous = append(previous, item)
+	}
+
+	item := op.Item{
+		Details: &op.Details{
+			Fields: []op.DetailsField{
+				{Designation: "username", Name: "username", Type: "T", Value: c.Username},
+				{Designation: "password", Name: "password", Type: "P", Value: c.Password},
+			},
+		},
+	}
+	item.Overview.Title = c.Host
+	item.Overview.URL = c.URL().String()
+	item.Overview.Tags = []string{Tag}
+
+	if _, err := h.Session.CreateItem("Login", h.Vault, &item); err != nil {
+		return errors.Wrap(err, "create item")
+	}
+
+	// The previous items are only deleted once the new one is created, so they are kept if
+	// creating fails.
+	for _, item := range previous {
+		if err := h.Session.DeleteItem(item.UUID); err != nil {
+			return errors.Wrap(err, "delete item")
+		}
+	}
+
+	return nil
+}
+
+// Erase deletes the items stored by the helper that match the credential. When a password is
+// given only items with that password are deleted.
+func (h *Helper) Erase(c *Credential) error {
+	if c.Host == "" {
+		return nil
+	}
+
+	items, err := h.match(c)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if !item.HasTag(Tag) {
+			continue
+		}
+
+		if c.Password != "" {
+			details, err := h.Session.GetItem(item.UUID)
+			if err != nil {
+				return errors.Wrap(err, "get item")
+			}
+			if details.Details == nil || details.Details.Value("password") != c.Password {
+				continue
+			}
+		}
+
+		if err := h.Session.DeleteItem(item.UUID); err != nil {
+			return errors.Wrap(err, "delete item")
+		}
+	}
+
+	return nil
+}
+
+// match returns the login items matching the credential url and username, best matches
+// first. Only items for the same host match.
+func (h *Helper) match(c *Credential) ([]op.Item, error) {
+	matches, err := h.Session.MatchURL(c.URL().String())
+	if err != nil {
+		return nil, errors.Wrap(err, "match url")
+	}
+
+	var items []op.Item
+	for _, match := range matches {
+		item := match.Item
+		if item.TemplateUUID != op.TemplateLogin || match.Rank < op.MatchHost {
+			continue
+		}
+
+		// The additional info of a login item is its username.
+		if c.Username != "" && item.Overview.AInfo != "" && item.Overview.AInfo != c.Username {
+			continue
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
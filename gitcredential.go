@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/michalnicp/1pass/gitcred"
+	"github.com/michalnicp/1pass/logging"
+	"github.com/michalnicp/1pass/op"
+)
+
+// gitCredential runs the git credential helper. Configure git to use it with
+//
+//  git config --global credential.helper '1pass --vault=Private'
+func gitCredential(args []string) error {
+	flags := flag.NewFlagSet("git-credential", flag.ContinueOnError)
+	vault := flags.String("vault", "", "vault to store new credentials in")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	session, err := cliSession()
+	if err != nil {
+		return err
+	}
+
+	openAuditLog()
+	defer auditLog.Close()
+
+	helper := gitcred.Helper{
+		Session: session,
+		Vault:   *vault,
+		Reveal: func(item *op.Item) {
+			recordAccess(logging.ActionReveal, item, "password", "git-credential")
+		},
+	}
+
+	return helper.Run(flags.Arg(0), os.Stdin, os.Stdout)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/michalnicp/1pass/importer"
+	"github.com/michalnicp/1pass/op"
+	"github.com/pkg/errors"
+)
+
+// importItems creates items from a KeePass XML, Bitwarden json or browser csv export.
+//
+//  1pass import --format csv --vault Private --dry-run passwords.csv
+//
+// Records are compared with the existing items in the vault and duplicates are skipped.
+// Imported records are logged to the progress file so an interrupted import can be resumed.
+// A dry run only reads the progress file.
+func importItems(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "csv", "input format, keepass, bitwarden or csv")
+	vault := flags.String("vault", "", "vault to create the items in, the default vault if empty")
+	dryRun := flags.Bool("dry-run", false, "only show what would be imported")
+	allowDuplicates := flags.Bool("allow-duplicates", false, "import items matching existing items")
+	progressPath := flags.String("progress", "", "progress log, defaults to the input file with .progress appended")
+
+	args, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) != 1 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	records, err := importer.Read(*format, f)
+	if err != nil {
+		return errors.Wrap(err, "read "+*format)
+	}
+
+	session, err := cliSession()
+	if err != nil {
+		return err
+	}
+
+	items, err := vaultItems(session, *vault)
+	if err != nil {
+		return err
+	}
+
+	if *progressPath == "" {
+		*progressPath = args[0] + ".progress"
+	}
+	openProgress := importer.OpenProgress
+	if *dryRun {
+		openProgress = importer.ReadProgress
+	}
+	progress, err := openProgress(*progressPath)
+	if err != nil {
+		return errors.Wrap(err, "open progress log")
+	}
+	defer progress.Close()
+
+	var created, skipped int
+	for i := range records {
+		record := &records[i]
+
+		status := "new"
+		if progress.Done(record) {
+			status = "imported"
+		} else if dup := importer.Duplicate(record, items); dup != nil && !*allowDuplicates {
+			status = fmt.Sprintf("duplicate of %q", dup.Overview.Title)
+		}
+
+		fmt.Printf("%-12s %-40s %-30s %s\n", strings.ToLower(record.Category), record.Item.Overview.Title, record.Username(), status)
+
+		if *dryRun || status != "new" {
+			skipped++
+			continue
+		}
+
+		item, err := session.CreateItem(record.Category, *vault, &record.Item)
+		if err != nil {
+			return errors.Wrapf(err, "create item %q", record.Item.Overview.Title)
+		}
+		if err := progress.Add(record, item.UUID); err != nil {
+			return errors.Wrap(err, "write progress log")
+		}
+		created++
+
+		// Detect duplicates within the file as well.
+		record.Item.UUID = item.UUID
+		record.Item.VaultUUID = item.VaultUUID
+		items = append(items, record.Item)
+	}
+
+	if *dryRun {
+		fmt.Printf("dry run, %d records\n", len(records))
+		return nil
+	}
+
+	fmt.Printf("created %d items, skipped %d\n", created, skipped)
+
+	return nil
+}
+
+// vaultItems returns the items in the vault, given by name or uuid. op doesn't tell which
+// vault is the default, so the items of every vault are returned if vault is empty.
+func vaultItems(session *op.Session, vault string) ([]op.Item, error) {
+	items, err := session.ListItems()
+	if err != nil {
+		return nil, errors.Wrap(err, "list items")
+	}
+	if vault == "" {
+		return items, nil
+	}
+
+	vaults, err := session.ListVaults()
+	if err != nil {
+		return nil, errors.Wrap(err, "list vaults")
+	}
+
+	var uuid string
+	for _, v := range vaults {
+		if v.UUID == vault || strings.EqualFold(v.Name, vault) {
+			uuid = v.UUID
+			break
+		}
+	}
+	if uuid == "" {
+		return nil, errors.Errorf("vault %q not found", vault)
+	}
+
+	var inVault []op.Item
+	for _, item := range items {
+		if item.VaultUUID == uuid {
+			inVault = append(inVault, item)
+		}
+	}
+	return inVault, nil
+}
@@ -0,0 +1,56 @@
+package importer
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/michalnicp/1pass/export"
+	"github.com/pkg/errors"
+)
+
+// ReadBitwarden reads the items of an unencrypted Bitwarden json export. Logins are imported
+// as logins, all other items as secure notes. Folder names are added as tags.
+func ReadBitwarden(r io.Reader) ([]Record, error) {
+	var file export.BitwardenExport
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, errors.Wrap(err, "decode bitwarden json")
+	}
+	if file.Encrypted {
+		return nil, errors.New("encrypted bitwarden exports are not supported")
+	}
+
+	folders := make(map[string]string)
+	for _, folder := range file.Folders {
+		folders[folder.ID] = folder.Name
+	}
+
+	var records []Record
+	for _, item := range file.Items {
+		var tags []string
+		if name := folders[item.FolderID]; name != "" {
+			tags = append(tags, name)
+		}
+
+		var username, password, totp string
+		var urls []string
+		if item.Login != nil {
+			username = item.Login.Username
+			password = item.Login.Password
+			totp = item.Login.TOTP
+			for _, u := range item.Login.URIs {
+				if u.URI != "" {
+					urls = append(urls, u.URI)
+				}
+			}
+		}
+
+		record := newRecord(item.Name, username, password, item.Notes, totp, urls, tags)
+		for _, field := range item.Fields {
+			addField(&record, "", field.Name, field.Value, field.Type == export.BitwardenFieldHidden)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
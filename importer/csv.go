@@ -0,0 +1,102 @@
+package importer
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// csvColumns maps the header names used by browsers and password managers to fields.
+var csvColumns = map[string]string{
+	"name":           "title",
+	"title":          "title",
+	"url":            "url",
+	"login_uri":      "url",
+	"username":       "username",
+	"login_username": "username",
+	"password":       "password",
+	"login_password": "password",
+	"note":           "notes",
+	"notes":          "notes",
+	"totp":           "totp",
+	"login_totp":     "totp",
+	"vault":          "tags",
+	"tags":           "tags",
+	"urls":           "urls",
+	"fields":         "fields",
+}
+
+// ReadCSV reads a csv export with a header row, eg. from Chrome (name,url,username,password),
+// Firefox (url,username,password,httpRealm,...) or 1pass export. Items without a title are
+// named by the host of their url.
+func ReadCSV(r io.Reader) ([]Record, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "read csv header")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		if field, ok := csvColumns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			if _, ok := columns[field]; !ok {
+				columns[field] = i
+			}
+		}
+	}
+	if _, ok := columns["password"]; !ok {
+		return nil, errors.New("csv has no password column")
+	}
+
+	var records []Record
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "read csv")
+		}
+
+		get := func(field string) string {
+			if i, ok := columns[field]; ok && i < len(row) {
+				return row[i]
+			}
+			return ""
+		}
+
+		var urls []string
+		for _, u := range append([]string{get("url")}, strings.Split(get("urls"), "\n")...) {
+			if u != "" {
+				urls = append(urls, u)
+			}
+		}
+
+		title := get("title")
+		if title == "" && len(urls) > 0 {
+			title = hostname(urls[0])
+		}
+
+		var tags []string
+		for _, tag := range strings.Split(get("tags"), ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+
+		record := newRecord(title, get("username"), get("password"), get("notes"), get("totp"), urls, tags)
+		for _, field := range strings.Split(get("fields"), "\n") {
+			if i := strings.IndexByte(field, '='); i > 0 {
+				addField(&record, "", field[:i], field[i+1:], false)
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,296 @@
+package importer
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/michalnicp/1pass/op"
+	"github.com/pkg/errors"
+)
+
+// Item categories, as accepted by op create item.
+const (
+	CategoryLogin      = "Login"
+	CategorySecureNote = "Secure Note"
+)
+
+// Record is an item read from an export file.
+type Record struct {
+
+	// Key identifies the record in the file, it is used to skip records that were already
+	// imported. It is derived from the title, username and url, never the password.
+	Key string
+
+	Category string
+	Item     op.Item
+}
+
+// Username returns the username of the record.
+func (r *Record) Username() string {
+	if r.Item.Details == nil {
+		return ""
+	}
+	return r.Item.Details.Value("username")
+}
+
+// newRecord returns a record with the fields set. Only the first url is set on the item, the
+// others are added as url fields of a section.
+func newRecord(title, username, password, notes, totp string, urls []string, tags []string) Record {
+	category := CategoryLogin
+	if username == "" && password == "" && len(urls) == 0 && totp == "" {
+		category = CategorySecureNote
+	}
+
+	details := op.Details{Notes: notes}
+	if category == CategoryLogin {
+		details.Fields = []op.DetailsField{
+			{Designation: "username", Name: "username", Type: "T", Value: username},
+			{Designation: "password", Name: "password", Type: "P", Value: password},
+		}
+	}
+
+	r := Record{
+		Category: category,
+		Item:     op.Item{Details: &details},
+	}
+	r.Item.Overview.Title = title
+	r.Item.Overview.Tags = tags
+
+	if len(urls) > 0 {
+		r.Item.Overview.URL = urls[0]
+		for _, u := range urls {
+			r.Item.Overview.URLs = append(r.Item.Overview.URLs, op.ItemURL{URL: u})
+		}
+	}
+	if len(urls) > 1 {
+		section := op.Section{Name: "urls", Title: "URLs"}
+		for i, u := range urls[1:] {
+			section.Fields = append(section.Fields, op.SectionField{
+				Type:  "URL",
+				Name:  "url" + strconv.Itoa(i+1),
+				Title: "website",
+				Value: u,
+			})
+		}
+		details.Sections = append(details.Sections, section)
+	}
+
+	if totp != "" {
+		if !strings.HasPrefix(totp, "otpauth://") {
+			totp = "otpauth://totp/" + url.PathEscape(title) + "?secret=" + url.QueryEscape(totp)
+		}
+		addField(&r, "", "one-time password", totp, true)
+	}
+
+	return r
+}
+
+// addField adds a field to the section with the title, creating the section if needed.
+// Fields exported as "section: field" are split into their section and field.
+func addField(r *Record, section, name, value string, concealed bool) {
+	if section == "" {
+		if i := strings.Index(name, ": "); i > 0 {
+			section, name = name[:i], name[i+2:]
+		}
+	}
+
+	typ := "string"
+	if concealed {
+		typ = "concealed"
+	}
+
+	sections := r.Item.Details.Sections
+	i := len(sections)
+	for j, s := range sections {
+		if s.Title == section {
+			i = j
+			break
+		}
+	}
+	if i == len(sections) {
+		sections = append(sections, op.Section{
+			Name:  "section" + strconv.Itoa(i),
+			Title: section,
+		})
+	}
+
+	sections[i].Fields = append(sections[i].Fields, op.SectionField{
+		Type:  typ,
+		Name:  "field" + strconv.Itoa(len(sections[i].Fields)),
+		Title: name,
+		Value: value,
+	})
+
+	// Keep one time passwords recognizable by 1Password.
+	if strings.HasPrefix(value, "otpauth://") {
+		sections[i].Fields[len(sections[i].Fields)-1].Name = "TOTP_" + keyHash(name + value)[:16]
+	}
+
+	r.Item.Details.Sections = sections
+}
+
+// setKeys sets the key of every record. Records with the same title, username and url are
+// numbered by their order in the file.
+func setKeys(records []Record) {
+	seen := make(map[string]int)
+	for i := range records {
+		r := &records[i]
+		base := keyHash(strings.Join([]string{r.Item.Overview.Title, r.Username(), r.Item.Overview.URL}, "\x00"))
+		seen[base]++
+		r.Key = fmt.Sprintf("%s-%d", base, seen[base])
+	}
+}
+
+func keyHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// Read reads the records from an export file of the format, keepass, bitwarden or csv.
+func Read(format string, r io.Reader) ([]Record, error) {
+	var records []Record
+	var err error
+	switch format {
+	case "keepass":
+		records, err = ReadKeePass(r)
+	case "bitwarden":
+		records, err = ReadBitwarden(r)
+	case "csv":
+		records, err = ReadCSV(r)
+	default:
+		return nil, errors.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	setKeys(records)
+
+	return records, nil
+}
+
+// Duplicate returns the existing item with the same title, or the same username and host,
+// as the record.
+func Duplicate(r *Record, items []op.Item) *op.Item {
+	host := hostname(r.Item.Overview.URL)
+	username := r.Username()
+
+	for i, item := range items {
+		if strings.EqualFold(item.Overview.Title, r.Item.Overview.Title) {
+			return &items[i]
+		}
+		if host != "" && username != "" && item.Overview.AInfo == username {
+			if hostname(item.Overview.URL) == host {
+				return &items[i]
+			}
+			for _, u := range item.Overview.URLs {
+				if hostname(u.URL) == host {
+					return &items[i]
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func hostname(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// Progress is an append only log of the imported records, used to resume an interrupted
+// import. Each line is a record key and the uuid of the created item.
+type Progress struct {
+	f    *os.File
+	done map[string]bool
+}
+
+// OpenProgress opens the progress log at path, creating it if it doesn't exist.
+func OpenProgress(path string) (*Progress, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	p := Progress{f: f}
+	if err := p.read(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// ReadProgress reads the progress log at path without creating or changing it, eg. for a
+// dry run. A missing log has no records. Records can't be added to the returned log.
+func ReadProgress(path string) (*Progress, error) {
+	var p Progress
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		p.done = make(map[string]bool)
+		return &p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := p.read(f); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (p *Progress) read(r io.Reader) error {
+	p.done = make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			p.done[fields[0]] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, "read progress log")
+	}
+
+	return nil
+}
+
+// Done reports whether the record was imported.
+func (p *Progress) Done(r *Record) bool {
+	return p.done[r.Key]
+}
+
+// Add records the record as imported as the item with the uuid.
+func (p *Progress) Add(r *Record, uuid string) error {
+	if p.f == nil {
+		return errors.New("progress log is read only")
+	}
+
+	if _, err := fmt.Fprintf(p.f, "%s %s\n", r.Key, uuid); err != nil {
+		return err
+	}
+	p.done[r.Key] = true
+	return p.f.Sync()
+}
+
+func (p *Progress) Close() error {
+	if p.f == nil {
+		return nil
+	}
+	return p.f.Close()
+}
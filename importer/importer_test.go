@@ -0,0 +1,197 @@
+package importer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/michalnicp/1pass/op"
+)
+
+// readFixture reads the records of a file in testdata.
+func readFixture(t *testing.T, format, name string) []Record {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	records, err := Read(format, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+// login returns the title, username, password and urls of a record.
+func login(r *Record) []string {
+	fields := []string{r.Item.Overview.Title, r.Username(), r.Item.Details.Value("password")}
+	for _, u := range r.Item.Overview.URLs {
+		fields = append(fields, u.URL)
+	}
+	return fields
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		want [][]string
+	}{
+		// name,url,username,password, items without a name are named by the host.
+		{"chrome.csv", [][]string{
+			{"example.com", "alice", "alice-password", "https://example.com/login"},
+			{"accounts.example.org", "bob", "pass,word", "https://accounts.example.org/"},
+		}},
+		// url,username,password,httpRealm,..., the other columns are ignored.
+		{"firefox.csv", [][]string{
+			{"example.com", "alice", "alice-password", "https://example.com"},
+			{"intranet.example.org", "bob", "bob-password", "https://intranet.example.org"},
+		}},
+	}
+	for _, test := range tests {
+		records := readFixture(t, "csv", test.name)
+		if len(records) != len(test.want) {
+			t.Fatalf("%s: got %d records, want %d", test.name, len(records), len(test.want))
+		}
+		for i := range records {
+			if records[i].Category != CategoryLogin {
+				t.Errorf("%s: record %d is a %s", test.name, i, records[i].Category)
+			}
+			if got := login(&records[i]); !reflect.DeepEqual(got, test.want[i]) {
+				t.Errorf("%s: got %q, want %q", test.name, got, test.want[i])
+			}
+			if n := len(records[i].Item.Details.Sections); n != 0 {
+				t.Errorf("%s: record %d has %d sections", test.name, i, n)
+			}
+		}
+		if records[0].Key == records[1].Key {
+			t.Errorf("%s: records have the same key", test.name)
+		}
+	}
+}
+
+func TestReadKeePass(t *testing.T) {
+	records := readFixture(t, "keepass", "keepass.xml")
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	r := &records[0]
+
+	want := []string{"Example", "alice", "alice-password",
+		"https://example.com",
+		"https://zero.example.com",
+		"https://one.example.com",
+		"https://two.example.com",
+		"https://ten.example.com",
+	}
+	if got := login(r); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if !reflect.DeepEqual(r.Item.Overview.Tags, []string{"Work"}) {
+		t.Errorf("got tags %q", r.Item.Overview.Tags)
+	}
+
+	types := make(map[string]string)
+	for _, section := range r.Item.Details.Sections {
+		for _, field := range section.Fields {
+			if section.Title == "" {
+				types[field.Title] = field.Type
+			}
+		}
+	}
+	wantTypes := map[string]string{"pin": "concealed", "recovery code": "concealed", "host": "string"}
+	if !reflect.DeepEqual(types, wantTypes) {
+		t.Fatalf("got fields %v, want %v", types, wantTypes)
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	item := func(title, username, url string) op.Item {
+		var item op.Item
+		item.Overview.Title = title
+		item.Overview.AInfo = username
+		item.Overview.URL = url
+		return item
+	}
+	other := item("Other", "carol", "https://other.example.com")
+	other.Overview.URLs = []op.ItemURL{{URL: "https://other.example.com"}, {URL: "https://www.example.org/login"}}
+	items := []op.Item{
+		item("Example", "alice", "https://example.com"),
+		other,
+	}
+
+	tests := []struct {
+		title, username, url string
+		want                 string
+	}{
+		{"example", "", "", "Example"},
+		{"Renamed", "alice", "https://EXAMPLE.com/login", "Example"},
+		{"Renamed", "carol", "https://www.example.org", "Other"},
+		{"Renamed", "bob", "https://example.com", ""},
+		{"Renamed", "alice", "https://sub.example.com", ""},
+		{"Renamed", "", "", ""},
+	}
+	for _, test := range tests {
+		r := newRecord(test.title, test.username, "password", "", "", []string{test.url}, nil)
+		if test.url == "" {
+			r = newRecord(test.title, test.username, "password", "", "", nil, nil)
+		}
+
+		var got string
+		if item := Duplicate(&r, items); item != nil {
+			got = item.Overview.Title
+		}
+		if got != test.want {
+			t.Errorf("%s %s %s: got %q, want %q", test.title, test.username, test.url, got, test.want)
+		}
+	}
+}
+
+func TestProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "importer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "export.csv.progress")
+
+	record := Record{Key: "key-1"}
+
+	// A dry run doesn't create the log.
+	p, err := ReadProgress(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Done(&record) {
+		t.Fatal("record done in a missing log")
+	}
+	if err := p.Add(&record, "uuid"); err == nil {
+		t.Fatal("added to a read only log")
+	}
+	p.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("log created by a dry run: %v", err)
+	}
+
+	p, err = OpenProgress(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Add(&record, "uuid"); err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+
+	for _, open := range []func(string) (*Progress, error){OpenProgress, ReadProgress} {
+		p, err := open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.Done(&record) || p.Done(&Record{Key: "key-2"}) {
+			t.Fatal("progress not read back")
+		}
+		p.Close()
+	}
+}
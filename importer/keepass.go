@@ -0,0 +1,130 @@
+package importer
+
+import (
+	"encoding/xml"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type keePassFile struct {
+	Root keePassGroup `xml:"Root>Group"`
+}
+
+type keePassGroup struct {
+	Name    string         `xml:"Name"`
+	Entries []keePassEntry `xml:"Entry"`
+	Groups  []keePassGroup `xml:"Group"`
+}
+
+type keePassEntry struct {
+	Tags    string `xml:"Tags"`
+	Strings []struct {
+		Key   string       `xml:"Key"`
+		Value keePassValue `xml:"Value"`
+	} `xml:"String"`
+}
+
+// keePassValue is the value of a string. KeePass marks protected values, eg. the password,
+// with ProtectInMemory in XML exports and Protected in the XML of a database.
+type keePassValue struct {
+	Text            string `xml:",chardata"`
+	Protected       string `xml:"Protected,attr"`
+	ProtectInMemory string `xml:"ProtectInMemory,attr"`
+}
+
+func (v keePassValue) protected() bool {
+	return strings.EqualFold(v.Protected, "True") || strings.EqualFold(v.ProtectInMemory, "True")
+}
+
+// ReadKeePass reads the entries of a KeePass 2 XML export. The names of the groups an entry
+// is in, below the root group, are added as tags.
+func ReadKeePass(r io.Reader) ([]Record, error) {
+	var file keePassFile
+	if err := xml.NewDecoder(r).Decode(&file); err != nil {
+		return nil, errors.Wrap(err, "decode keepass xml")
+	}
+
+	var records []Record
+	var walk func(group keePassGroup, path []string)
+	walk = func(group keePassGroup, path []string) {
+		for _, entry := range group.Entries {
+			records = append(records, keePassRecord(entry, path))
+		}
+		for _, g := range group.Groups {
+			walk(g, append(path[:len(path):len(path)], g.Name))
+		}
+	}
+	walk(file.Root, nil)
+
+	return records, nil
+}
+
+func keePassRecord(entry keePassEntry, groups []string) Record {
+	values := make(map[string]string)
+	protected := make(map[string]bool)
+	var custom []string
+	for _, s := range entry.Strings {
+		values[s.Key] = s.Value.Text
+		protected[s.Key] = s.Value.protected()
+		switch {
+		case s.Key == "Title", s.Key == "UserName", s.Key == "Password", s.Key == "URL", s.Key == "Notes":
+		case s.Key == "otp", s.Key == "TOTP Seed", strings.HasPrefix(s.Key, "TOTP Settings"):
+		case strings.HasPrefix(s.Key, "KP2A_URL"):
+		default:
+			custom = append(custom, s.Key)
+		}
+	}
+
+	var urls []string
+	if values["URL"] != "" {
+		urls = append(urls, values["URL"])
+	}
+	var extra []string
+	for key := range values {
+		if strings.HasPrefix(key, "KP2A_URL") && values[key] != "" {
+			extra = append(extra, key)
+		}
+	}
+	sort.Slice(extra, func(i, j int) bool {
+		return kp2aURLIndex(extra[i]) < kp2aURLIndex(extra[j])
+	})
+	for _, key := range extra {
+		urls = append(urls, values[key])
+	}
+
+	totp := values["otp"]
+	if totp == "" {
+		totp = values["TOTP Seed"]
+	}
+
+	tags := append([]string{}, groups...)
+	for _, tag := range strings.FieldsFunc(entry.Tags, func(r rune) bool { return r == ';' || r == ',' }) {
+		tags = append(tags, strings.TrimSpace(tag))
+	}
+
+	record := newRecord(values["Title"], values["UserName"], values["Password"], values["Notes"], totp, urls, tags)
+	for _, key := range custom {
+		addField(&record, "", key, values[key], protected[key])
+	}
+
+	return record
+}
+
+// kp2aURLIndex returns the number of an additional url key, KP2A_URL is 0, KP2A_URL_1 is 1
+// and so on. Keys without a number sort last.
+func kp2aURLIndex(key string) int {
+	suffix := strings.TrimPrefix(strings.TrimPrefix(key, "KP2A_URL"), "_")
+	if suffix == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(suffix)
+	if err != nil {
+		return math.MaxInt32
+	}
+	return n
+}
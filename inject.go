@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/michalnicp/1pass/secretref"
+	"github.com/pkg/errors"
+)
+
+// inject renders a template replacing {{ op://vault/item/[section/]field }} references with
+// their values.
+//
+//  1pass inject -i config.yml.tpl -o config.yml
+func inject(args []string) error {
+	flags := flag.NewFlagSet("inject", flag.ContinueOnError)
+	in := flags.String("i", "", "template file, read from stdin if empty")
+	out := flags.String("o", "", "output file, written to stdout if empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var template []byte
+	var err error
+	if *in == "" {
+		template, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		template, err = ioutil.ReadFile(*in)
+	}
+	if err != nil {
+		return errors.Wrap(err, "read template")
+	}
+
+	session, err := cliSession()
+	if err != nil {
+		return err
+	}
+
+	openAuditLog()
+	defer auditLog.Close()
+
+	resolver := secretref.Resolver{
+		Session: session,
+		Reveal:  revealFunc("inject"),
+	}
+
+	rendered, err := resolver.Render(template)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err := os.Stdout.Write(rendered)
+		return err
+	}
+
+	return writeFileSecure(*out, rendered)
+}
+
+// writeFileSecure atomically replaces the file with data, readable only by the owner.
+func writeFileSecure(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return errors.Wrap(err, "create file")
+	}
+	defer os.Remove(f.Name())
+
+	// TempFile creates files with 0600 already, chmod in case the umask is unusual.
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return errors.Wrap(err, "chmod file")
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return errors.Wrap(err, "write file")
+	}
+
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "close file")
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return errors.Wrap(err, "rename file")
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package ipc
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// PrivateDir creates the directory with mode 0700 if it doesn't exist and checks that it is a
+// directory, not a symlink, owned by the current user and only accessible by them. Sockets
+// and lock files are only created in such directories, another user could otherwise create
+// the directory first and replace them. The parent directory must exist.
+func PrivateDir(dir string) error {
+	if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+		return errors.Wrap(err, "create directory")
+	}
+
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+
+	switch stat, ok := info.Sys().(*syscall.Stat_t); {
+	case info.Mode()&os.ModeSymlink != 0:
+		return errors.Errorf("%s is a symlink", dir)
+	case !info.IsDir():
+		return errors.Errorf("%s is not a directory", dir)
+	case !ok || int(stat.Uid) != os.Getuid():
+		return errors.Errorf("%s is not owned by the current user", dir)
+	case info.Mode().Perm()&0077 != 0:
+		return errors.Errorf("%s is accessible by other users, mode %v", dir, info.Mode().Perm())
+	}
+
+	return nil
+}
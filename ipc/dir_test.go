@@ -0,0 +1,55 @@
+package ipc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrivateDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A new directory is created private, an existing private one is accepted.
+	private := filepath.Join(dir, "private")
+	for i := 0; i < 2; i++ {
+		if err := PrivateDir(private); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info, err := os.Stat(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Fatalf("created with mode %v", info.Mode().Perm())
+	}
+
+	shared := filepath.Join(dir, "shared")
+	if err := os.Mkdir(shared, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(shared, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(private, link); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{shared, link, file, filepath.Join(dir, "missing", "dir")} {
+		if err := PrivateDir(path); err == nil {
+			t.Errorf("%s: expected an error", filepath.Base(path))
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package ipc
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	errors2 "github.com/pkg/errors"
+)
+
+// ErrRunning is returned by Acquire when another instance holds the lock.
+var ErrRunning = errors.New("1pass is already running")
+
+// Acquire makes this process the single running instance by locking a file next to the
+// socket at socketPath. The lock is held until the returned file is closed or the process
+// exits.
+func Acquire(socketPath string) (io.Closer, error) {
+	if err := PrivateDir(filepath.Dir(socketPath)); err != nil {
+		return nil, errors2.Wrap(err, "socket directory")
+	}
+
+	path := filepath.Join(filepath.Dir(socketPath), "1pass.lock")
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors2.Wrap(err, "open lock file")
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, errors2.WithStack(ErrRunning)
+		}
+		return nil, errors2.Wrap(err, "lock")
+	}
+
+	return f, nil
+}
@@ -0,0 +1,79 @@
+// Package ipc lets other processes control the running 1pass using JSON-RPC over a unix
+// socket.
+package ipc
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// Name is the name methods are registered under, eg. 1pass.Lock.
+const Name = "1pass"
+
+// Empty is the argument and reply of methods without any.
+type Empty struct{}
+
+// SocketPath returns the path of the socket, $XDG_RUNTIME_DIR/1pass/1pass.sock, or
+// 1pass-<uid>/1pass.sock in the temporary directory without XDG_RUNTIME_DIR. The directory of
+// the socket is checked by PrivateDir.
+func SocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "1pass", "1pass.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("1pass-%d", os.Getuid()), "1pass.sock")
+}
+
+// ListenAndServe listens on the unix socket at path and serves the exported methods of rcvr.
+// The socket is only accessible by the current user.
+func ListenAndServe(path string, rcvr interface{}) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName(Name, rcvr); err != nil {
+		return errors.Wrap(err, "register methods")
+	}
+
+	if err := PrivateDir(filepath.Dir(path)); err != nil {
+		return errors.Wrap(err, "socket directory")
+	}
+
+	// Remove a socket left over from a previous run.
+	os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return errors.Wrap(err, "listen")
+	}
+	defer l.Close()
+
+	if err := os.Chmod(path, 0600); err != nil {
+		return errors.Wrap(err, "chmod socket")
+	}
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return errors.Wrap(err, "accept")
+		}
+
+		go func() {
+			defer conn.Close()
+			server.ServeCodec(jsonrpc.NewServerCodec(conn))
+		}()
+	}
+}
+
+// Call calls the method of the running 1pass listening on the socket at path.
+func Call(path, method string, args, reply interface{}) error {
+	client, err := jsonrpc.Dial("unix", path)
+	if err != nil {
+		return errors.Wrap(err, "connect to 1pass")
+	}
+	defer client.Close()
+
+	return client.Call(Name+"."+method, args, reply)
+}
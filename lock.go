@@ -0,0 +1,182 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/golang-ui/nuklear/nk"
+	"github.com/michalnicp/1pass/ipc"
+	"github.com/michalnicp/1pass/logging"
+	"github.com/michalnicp/1pass/securemem"
+	"github.com/pkg/errors"
+)
+
+// unlockTimeout is how long requestUnlock waits for the user to unlock.
+const unlockTimeout = 2 * time.Minute
+
+// touch records user activity, postponing the auto lock.
+func (s *UIState) touch() {
+	s.lastActivity = time.Now()
+}
+
+// idle reports whether the user has been inactive for longer than the lock timeout.
+func (s *UIState) idle() bool {
+	timeout := s.config.LockTimeout.Duration
+	return timeout > 0 && time.Since(s.lastActivity) > timeout
+}
+
+// lock locks the sessions of all accounts and forgets the items shown in the ui.
+func (s *UIState) lock() {
+	locked := false
+	for _, session := range s.sessions.All() {
+		if session.Valid() {
+			session.Lock()
+			locked = true
+		}
+	}
+
+	if locked {
+		s.forget()
+	}
+}
+
+// forget removes the items shown in the ui and clears the secrets typed in it.
+func (s *UIState) forget() {
+	if s.searchCancel != nil {
+		s.searchCancel()
+	}
+	s.items = nil
+	s.searchOnce = sync.Once{}
+	s.searchResults = nil
+	s.selectedItem = nil
+	s.searchQueryLen = 0
+	s.showAudit = false
+	s.auditReport = nil
+	s.statusText = ""
+	s.clearSecrets()
+}
+
+// requestUnlock shows the window and blocks until the user unlocks the session. It reports
+// whether the session was unlocked before the timeout. It must not be called from the main
+// thread.
+func (s *UIState) requestUnlock(window *glfw.Window) bool {
+	s.queue(window.Show)
+
+	deadline := time.Now().Add(unlockTimeout)
+	for time.Now().Before(deadline) {
+		if s.sessions.Get().Valid() {
+			return true
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return false
+}
+
+// clearSecrets overwrites the master password and pin typed in the ui.
+func (s *UIState) clearSecrets() {
+	securemem.Wipe(s.masterPassword)
+	s.masterPasswordLen = 0
+	securemem.Wipe(s.pin)
+	s.pinLen = 0
+}
+
+// Lock draws the lock view.
+func Lock(window *glfw.Window, ctx *nk.Context, state *UIState) {
+	// Unlock with the pin if one is set, the master password otherwise.
+	session := state.session
+	hasPIN := session.HasPIN()
+
+	submit := func() {
+		state.isUnlocking = true
+
+		buf, length := state.masterPassword, &state.masterPasswordLen
+		if hasPIN {
+			buf, length = state.pin, &state.pinLen
+		}
+		secret, err := takeSecret(buf, length)
+		if err != nil {
+			logging.Error("unlock", "err", err)
+			state.isUnlocking = false
+			return
+		}
+
+		go func() {
+			defer secret.Destroy()
+			defer state.queue(func() {
+				state.isUnlocking = false
+			})
+
+			var err error
+			if hasPIN {
+				err = session.UnlockPIN(secret.Bytes())
+			} else {
+				err = session.Unlock(secret.Bytes())
+			}
+			if err != nil {
+				logging.Error("unlock", "err", err)
+				state.queue(func() {
+					state.statusText = fmt.Sprintf("unlock: %v", errors.Cause(err))
+				})
+				return
+			}
+
+			state.queue(func() {
+				state.statusText = ""
+				state.touch()
+			})
+		}()
+	}
+
+	if window.GetKey(glfw.KeyEnter) == glfw.Press && !state.isUnlocking {
+		submit()
+	}
+
+	width, height := window.GetSize()
+	bounds := nk.NkRect(0, 0, float32(width), float32(height))
+	if nk.NkBegin(ctx, "lock", bounds, nk.WindowNoScrollbar) > 0 {
+		nk.NkLayoutRowDynamic(ctx, 0, 1)
+
+		nk.NkLabel(ctx, fmt.Sprintf("Locked, unlock %s", session.Email), nk.TextLeft)
+
+		if hasPIN {
+			nk.NkLabel(ctx, "PIN", nk.TextLeft)
+			nk.NkEditFocus(ctx, nk.EditField|nk.EditGotoEndOnActivate)
+			PasswordEdit(ctx, state.mask, state.pin, &state.pinLen)
+		} else {
+			nk.NkLabel(ctx, "Master Password", nk.TextLeft)
+			nk.NkEditFocus(ctx, nk.EditField|nk.EditGotoEndOnActivate)
+			PasswordEdit(ctx, state.mask, state.masterPassword, &state.masterPasswordLen)
+		}
+
+		// Padding.
+		nk.NkLayoutRowStatic(ctx, 10, 0, 0)
+
+		nk.NkLayoutRowDynamic(ctx, 30, 1)
+		if nk.NkButtonLabel(ctx, "Unlock") > 0 && !state.isUnlocking {
+			submit()
+		}
+
+		nk.NkLayoutRowDynamic(ctx, 0, 1)
+		nk.NkLabel(ctx, state.statusText, nk.TextLeft)
+
+		nk.NkEnd(ctx)
+	}
+}
+
+// lockSession locks the session of the running 1pass.
+func lockSession(args []string) error {
+	flags := flag.NewFlagSet("lock", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	return ipc.Call(ipc.SocketPath(), "Lock", ipc.Empty{}, &ipc.Empty{})
+}
@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Audit actions.
+const (
+	ActionCopy   = "copy"
+	ActionReveal = "reveal"
+)
+
+// AuditEvent records access to a field of an item. The value is never recorded.
+type AuditEvent struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Item   string    `json:"item"` // uuid
+	Title  string    `json:"title"`
+	Field  string    `json:"field"`
+	Source string    `json:"source"` // ui or the name of the command
+}
+
+// AuditLog is an append only file of audit events, one json object per line.
+type AuditLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenAuditLog opens the audit log at path for appending, creating it if needed.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, errors.Wrap(err, "create audit log directory")
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "open audit log")
+	}
+
+	return &AuditLog{f: f}, nil
+}
+
+// Record appends an event to the log. The time is set if zero.
+func (l *AuditLog) Record(event AuditEvent) error {
+	if l == nil {
+		return nil
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "encode audit event")
+	}
+	b = append(b, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.f.Write(b); err != nil {
+		return errors.Wrap(err, "write audit log")
+	}
+
+	return nil
+}
+
+// Close closes the log.
+func (l *AuditLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.f.Close()
+}
+
+// ReadAuditLog returns the last n events of the audit log at path, most recent first. Lines
+// that can't be decoded are skipped.
+func ReadAuditLog(path string, n int) ([]AuditEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "open audit log")
+	}
+	defer f.Close()
+
+	var events []AuditEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+
+		events = append(events, event)
+		if len(events) > n {
+			events = events[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "read audit log")
+	}
+
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+
+	return events, nil
+}
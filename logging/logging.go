@@ -0,0 +1,187 @@
+// Package logging writes leveled, structured log lines with secrets redacted.
+//
+// Lines have the form
+//
+//  2018-05-01T12:00:00+02:00 level=info msg="list items" count=120
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Level is the severity of a log line.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the level with the name.
+func ParseLevel(name string) (Level, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(n, name) {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// Redacted replaces secrets in log lines.
+const Redacted = "[redacted]"
+
+var (
+
+	// Session tokens in op arguments and the environment.
+	sessionRe = regexp.MustCompile(`(--session=|OP_SESSION_\w+=)\S+`)
+
+	// Secret keys, eg. A3-ABCDEF-GHIJKL-MNOPQ-RSTUV-WXYZ1-23456.
+	secretKeyRe = regexp.MustCompile(`\b[A-Z]\d(-[A-Z0-9]{5,6}){5,6}\b`)
+
+	// Private keys, possibly cut off.
+	privateKeyRe = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?(-----END [A-Z ]*PRIVATE KEY-----|$)`)
+
+	// Long random looking strings, eg. session tokens and keys.
+	randomRe = regexp.MustCompile(`[A-Za-z0-9_\-]{32,}`)
+)
+
+// Redact replaces anything in s that looks like a session token, secret key or private key.
+func Redact(s string) string {
+	s = sessionRe.ReplaceAllString(s, "${1}"+Redacted)
+	s = secretKeyRe.ReplaceAllString(s, Redacted)
+	s = privateKeyRe.ReplaceAllString(s, Redacted)
+	s = randomRe.ReplaceAllStringFunc(s, func(match string) string {
+
+		// Words joined by dashes can be long too, but rarely mix letters and digits.
+		if strings.IndexAny(match, "0123456789") < 0 || strings.IndexFunc(match, unicode.IsLetter) < 0 {
+			return match
+		}
+		return Redacted
+	})
+	return s
+}
+
+// Logger writes log lines at or above its level.
+type Logger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level Level
+}
+
+// New returns a logger writing lines at or above the level to w.
+func New(w io.Writer, level Level) *Logger {
+	return &Logger{w: w, level: level}
+}
+
+// SetLevel changes the minimum level of the lines written.
+func (l *Logger) SetLevel(level Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+}
+
+// Debug logs a message with key value pairs at debug level.
+func (l *Logger) Debug(msg string, kv ...interface{}) { l.log(LevelDebug, msg, kv) }
+
+// Info logs a message with key value pairs at info level.
+func (l *Logger) Info(msg string, kv ...interface{}) { l.log(LevelInfo, msg, kv) }
+
+// Warn logs a message with key value pairs at warn level.
+func (l *Logger) Warn(msg string, kv ...interface{}) { l.log(LevelWarn, msg, kv) }
+
+// Error logs a message with key value pairs at error level.
+func (l *Logger) Error(msg string, kv ...interface{}) { l.log(LevelError, msg, kv) }
+
+func (l *Logger) log(level Level, msg string, kv []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if level < l.level {
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(time.Now().Format(time.RFC3339))
+	buf.WriteString(" level=")
+	buf.WriteString(level.String())
+	buf.WriteString(" msg=")
+	buf.WriteString(quote(Redact(msg)))
+
+	for i := 0; i < len(kv); i += 2 {
+		key := fmt.Sprint(kv[i])
+		value := "MISSING"
+		if i+1 < len(kv) {
+			value = fmt.Sprint(kv[i+1])
+		}
+
+		buf.WriteByte(' ')
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(quote(Redact(value)))
+	}
+	buf.WriteByte('\n')
+
+	l.w.Write(buf.Bytes())
+}
+
+// quote quotes s if it contains spaces, quotes or control characters.
+func quote(s string) string {
+	if s == "" || strings.IndexFunc(s, func(r rune) bool { return r <= ' ' || r == '"' || r == '=' }) >= 0 {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
+
+// RedactWriter redacts secrets from the lines written to it, used for the standard logger of
+// packages that don't use this one.
+type RedactWriter struct {
+	w io.Writer
+}
+
+// NewRedactWriter returns a writer redacting secrets before writing to w.
+func NewRedactWriter(w io.Writer) *RedactWriter {
+	return &RedactWriter{w: w}
+}
+
+func (w *RedactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+var std = New(os.Stderr, LevelInfo)
+
+// SetLevel changes the level of the default logger.
+func SetLevel(level Level) { std.SetLevel(level) }
+
+// Debug logs to the default logger at debug level.
+func Debug(msg string, kv ...interface{}) { std.log(LevelDebug, msg, kv) }
+
+// Info logs to the default logger at info level.
+func Info(msg string, kv ...interface{}) { std.log(LevelInfo, msg, kv) }
+
+// Warn logs to the default logger at warn level.
+func Warn(msg string, kv ...interface{}) { std.log(LevelWarn, msg, kv) }
+
+// Error logs to the default logger at error level.
+func Error(msg string, kv ...interface{}) { std.log(LevelError, msg, kv) }
@@ -1,31 +1,40 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"runtime"
-	"sync"
+	"time"
 
 	"github.com/go-gl/gl/v3.2-core/gl"
 	"github.com/go-gl/glfw/v3.2/glfw"
 	"github.com/golang-ui/nuklear/nk"
+	"github.com/michalnicp/1pass/audit"
+	"github.com/michalnicp/1pass/config"
+	"github.com/michalnicp/1pass/ipc"
+	"github.com/michalnicp/1pass/logging"
 	"github.com/michalnicp/1pass/op"
+	"github.com/michalnicp/1pass/secretservice"
+	"github.com/michalnicp/1pass/securemem"
+	"github.com/michalnicp/1pass/sshagent"
 	"github.com/michalnicp/1pass/tray"
 	"github.com/pkg/errors"
 )
 
 const (
-	windowWidth  = 400
-	windowHeight = 400
-
 	maxVertexBuffer  = 512 * 1024
 	maxElementBuffer = 128 * 1024
 )
 
 var (
-	mu      sync.Mutex
-	session *op.Session
+	sshAgentSocket = flag.String("ssh-agent", "", "serve ssh keys from 1Password on the unix socket")
+	sshAgentVaults = flag.String("ssh-agent-vaults", "", "comma separated vaults to serve ssh keys from, all vaults if empty")
+	hibpFile       = flag.String("hibp", "", "Have I Been Pwned password file ordered by hash to check passwords against")
+	lockTimeout    = flag.Duration("lock-timeout", 5*time.Minute, "lock after being idle for this long, never if 0")
+	logLevel       = flag.String("log-level", "info", "minimum level of logged messages: debug, info, warn or error")
+	offline        = flag.Bool("offline", false, "keep an encrypted copy of the items to use when 1Password can't be reached")
 )
 
 func main() {
@@ -35,10 +44,75 @@ func main() {
 	defer func() { os.Exit(code) }()
 
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	log.SetOutput(logging.NewRedactWriter(os.Stderr))
+
+	// Keep secrets out of core dumps.
+	if err := securemem.DisableCoreDumps(); err != nil {
+		logging.Warn("disable core dumps", "err", err)
+	}
+
+	// Run a subcommand instead of the ui.
+	if cmd, args, ok := lookupCommand(os.Args); ok {
+		if err := cmd(args); err != nil {
+			switch err := err.(type) {
+			case exitError:
+				code = int(err)
+			default:
+				if err != flag.ErrHelp {
+					fmt.Fprintf(os.Stderr, "%v\n", err)
+				}
+				code = 1
+			}
+		}
+		return
+	}
+
+	flag.Parse()
+
+	// Read the config file.
+	configPath, err := config.Path()
+	if err != nil {
+		logging.Error("config path", "err", err)
+		code = 1
+		return
+	}
+	fileConfig, cfg, err := loadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", configPath, err)
+		code = 2
+		return
+	}
+	applyOptions(cfg)
+
+	// Only run a single instance, other invocations forward their arguments to it.
+	action, err := parseInstanceAction(flag.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		flag.Usage()
+		code = 2
+		return
+	}
+	instance, err := ipc.Acquire(ipc.SocketPath())
+	if errors.Cause(err) == ipc.ErrRunning {
+		if err := action.forward(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			code = 1
+		}
+		return
+	}
+	if err != nil {
+		logging.Error("acquire instance lock", "err", err)
+		code = 1
+		return
+	}
+	defer instance.Close()
+
+	openAuditLog()
+	defer auditLog.Close()
 
 	// Initialize glfw.
 	if err := glfw.Init(); err != nil {
-		log.Printf("initialize glfw: %v", err)
+		logging.Error("initialize glfw", "err", err)
 		code = 1
 		return
 	}
@@ -52,7 +126,7 @@ func main() {
 	glfw.WindowHint(glfw.Decorated, glfw.False)
 	glfw.WindowHint(glfw.Visible, glfw.False) // Show window after centering it.
 
-	window, err := glfw.CreateWindow(windowWidth, windowHeight, "1pass", nil, nil)
+	window, err := glfw.CreateWindow(cfg.Width, cfg.Height, "1pass", nil, nil)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "create window: %v", err)
 		code = 1
@@ -83,37 +157,118 @@ func main() {
 	ctx := nk.NkPlatformInit(window, nk.PlatformInstallCallbacks)
 	atlas := nk.NewFontAtlas()
 	nk.NkFontStashBegin(&atlas)
-	sansFont := nk.NkFontAtlasAddFromFile(atlas, "assets/FreeSans.ttf", 18, nil)
+	sansFont := nk.NkFontAtlasAddFromFile(atlas, cfg.Font, 18, nil)
 	nk.NkFontStashEnd()
 	if sansFont != nil {
 		nk.NkStyleSetFont(ctx, sansFont.Handle())
 		font = sansFont
 	}
 
-	// Initialize system tray icon.
-	tray.Init()
-
 	// Read 1Password config and try to load existing session.
-	session, err = op.NewSessionFromConfig()
+	session, err := op.NewSessionFromConfig()
 	if err != nil {
 		if cerr := errors.Cause(err); cerr != op.ErrInvalidOPConfig {
-			log.Printf("create session: %v", err)
+			logging.Error("create session", "err", err)
 			code = 1
 			return
 		}
 	}
+	sessions := newSessionStore(session)
 
 	// Initialize ui state.
-	state := NewUIState()
+	state, err := NewUIState(cfg, sessions)
+	if err != nil {
+		logging.Error("initialize ui", "err", err)
+		code = 1
+		return
+	}
+	watchActivity(window, state.touch)
+	state.configPath = configPath
+	state.fileConfig = fileConfig
+	if watcher := watchConfig(configPath, window, state); watcher != nil {
+		defer watcher.Close()
+	}
+
+	if action.method == "Search" {
+		state.setSearchQuery(action.query)
+	}
+
+	// Initialize system tray icon.
+	icon, err := tray.New(cfg.Tray, func() { toggleWindow(window) })
+	if err != nil {
+		logging.Error("create tray icon", "err", err)
+	} else {
+		defer icon.Close()
+	}
+
+	// Listen for commands from other processes.
+	go func() {
+		if err := ipc.ListenAndServe(ipc.SocketPath(), &service{state: state, window: window}); err != nil {
+			logging.Error("ipc", "err", err)
+		}
+	}()
+
+	if cfg.HIBP != "" {
+		db, err := audit.OpenBreachDB(cfg.HIBP)
+		if err != nil {
+			logging.Error("open breach file", "err", err)
+			code = 1
+			return
+		}
+		defer db.Close()
+		state.auditOptions.BreachDB = db
+	}
+
+	// Start the ssh agent.
+	if cfg.SSHAgent.Socket != "" {
+		agent := sshagent.Agent{
+			Session: sessions.Get,
+			Vaults:  cfg.SSHAgent.Vaults,
+			Confirm: func(text string) bool { return state.confirm(window, text) },
+			Reveal: func(item *op.Item) {
+				recordAccess(logging.ActionReveal, item, "private key", "ssh-agent")
+			},
+		}
+
+		go func() {
+			if err := agent.ListenAndServe(cfg.SSHAgent.Socket); err != nil {
+				logging.Error("ssh agent", "err", err)
+			}
+		}()
+	}
+
+	// Serve the secret service.
+	if cfg.SecretService.Enabled {
+		service := secretservice.Service{
+			Session: sessions.Get,
+			Vaults:  cfg.SecretService.Vaults,
+			Unlock:  func() bool { return state.requestUnlock(window) },
+			Reveal: func(item *op.Item) {
+				recordAccess(logging.ActionReveal, item, "password", "secret-service")
+			},
+		}
+		if err := service.Start(); err != nil {
+			logging.Error("secret service", "err", err)
+		} else {
+			defer service.Close()
+		}
+	}
 
 	// Main loop.
-	for {
+	var trayMenu []tray.Item
+	for !window.ShouldClose() {
 
 		// Process window events.
 		glfw.PollEvents()
 
-		// Run the gtk main loop without blocking.
-		tray.Loop()
+		// Update the tray menu if it changed and handle its events without blocking.
+		if icon != nil {
+			if menu := state.trayMenu(window); !tray.SameLayout(trayMenu, menu) {
+				icon.SetMenu(menu)
+				trayMenu = menu
+			}
+			icon.Loop()
+		}
 
 		// Draw the ui.
 		UI(window, ctx, state)
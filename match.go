@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/michalnicp/1pass/config"
+	"github.com/michalnicp/1pass/op"
+	"github.com/pkg/errors"
+)
+
+// matchItems prints the items matching a url, best matches first.
+func matchItems(args []string) error {
+	flags := flag.NewFlagSet("match", flag.ContinueOnError)
+	logins := flags.Bool("logins", false, "only print login items")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	// Equivalent domains are read from the config file.
+	path, err := config.Path()
+	if err != nil {
+		return errors.Wrap(err, "get config path")
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return errors.Wrap(err, "load config")
+	}
+	if err := cfg.Validate(); err != nil {
+		return errors.Wrap(err, "load config")
+	}
+	applyOptions(cfg)
+
+	session, err := cliSession()
+	if err != nil {
+		return err
+	}
+
+	matches, err := session.MatchURL(flags.Arg(0))
+	if err != nil {
+		return errors.Wrap(err, "match url")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, m := range matches {
+		if *logins && m.Item.TemplateUUID != op.TemplateLogin {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.Item.UUID, m.Rank, m.Item.Overview.Title, m.Item.Overview.AInfo)
+	}
+	return w.Flush()
+}
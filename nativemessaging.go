@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/michalnicp/1pass/ipc"
+	"github.com/michalnicp/1pass/logging"
+	"github.com/michalnicp/1pass/nativemsg"
+	"github.com/michalnicp/1pass/op"
+	"github.com/pkg/errors"
+)
+
+// nativeMessaging runs the native messaging host of the browser extension. Requests are
+// forwarded to the running 1pass. Browsers start the host with the origin of the extension
+// or the path of the manifest as arguments, which are ignored.
+func nativeMessaging(args []string) error {
+	return nativemsg.Serve(os.Stdin, os.Stdout, func(req nativemsg.Request) nativemsg.Response {
+		var method string
+		switch req.Action {
+		case nativemsg.ActionQuery:
+			method = "Query"
+		case nativemsg.ActionFill:
+			method = "Fill"
+		default:
+			return nativemsg.Response{Error: fmt.Sprintf("unknown action %q", req.Action)}
+		}
+
+		var resp nativemsg.Response
+		if err := ipc.Call(ipc.SocketPath(), method, req, &resp); err != nil {
+			return nativemsg.Response{Error: err.Error()}
+		}
+		return resp
+	})
+}
+
+// Query returns the logins matching the url of the tab.
+func (s *service) Query(req nativemsg.Request, reply *nativemsg.Response) error {
+	items, _, err := matchLogins(s.state.sessions.Get(), req.URL, op.MatchEquivalent)
+	if err != nil {
+		return err
+	}
+
+	reply.Logins = []nativemsg.Login{}
+	for _, item := range items {
+		reply.Logins = append(reply.Logins, nativemsg.Login{
+			UUID:     item.UUID,
+			Title:    item.Overview.Title,
+			Username: item.Overview.AInfo,
+		})
+	}
+
+	return nil
+}
+
+// Fill returns the username and password of the login after the user allows it. Only logins
+// for the host of the tab are filled, logins of other hosts of the domain are only listed.
+func (s *service) Fill(req nativemsg.Request, reply *nativemsg.Response) error {
+	session := s.state.sessions.Get()
+	items, u, err := matchLogins(session, req.URL, op.MatchHost)
+	if err != nil {
+		return err
+	}
+
+	var item *op.Item
+	for i := range items {
+		if items[i].UUID == req.UUID {
+			item = &items[i]
+		}
+	}
+	if item == nil {
+		return errors.Errorf("no login %s for %s", req.UUID, u.Host)
+	}
+
+	text := fmt.Sprintf("Allow the browser to fill %q on %s?", item.Overview.Title, u.Host)
+	if !s.state.confirm(s.window, text) {
+		return errors.New("fill denied")
+	}
+
+	details, err := session.GetItem(item.UUID)
+	if err != nil {
+		return errors.Wrap(err, "get item")
+	}
+	if details.Details == nil {
+		return errors.New("login has no details")
+	}
+
+	reply.Username = details.Details.Value("username")
+	reply.Password = details.Details.Value("password")
+	recordAccess(logging.ActionReveal, item, "password", "browser")
+
+	return nil
+}
+
+// matchLogins returns the login items of the session matching the url of a tab at least as
+// well as min, best matches first.
+func matchLogins(session *op.Session, rawurl string, min op.MatchRank) ([]op.Item, *url.URL, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "parse url")
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, nil, errors.Errorf("unsupported url scheme %q", u.Scheme)
+	}
+
+	if session.Locked() {
+		return nil, nil, errors.WithStack(op.ErrLocked)
+	}
+	if !session.Valid() {
+		return nil, nil, errors.New("not signed in")
+	}
+
+	matches, err := session.MatchURL(u.String())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "match url")
+	}
+
+	var items []op.Item
+	for _, match := range matches {
+		if match.Item.TemplateUUID == op.TemplateLogin && match.Rank >= min {
+			items = append(items, match.Item)
+		}
+	}
+
+	return items, u, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/michalnicp/1pass/op"
+	"github.com/michalnicp/1pass/op/optest"
+)
+
+func TestMain(m *testing.M) {
+	optest.Main(m)
+}
+
+func TestMatchLogins(t *testing.T) {
+	login := func(title, url string) op.Item {
+		item := op.Item{TemplateUUID: op.TemplateLogin}
+		item.Overview.Title = title
+		item.Overview.URL = url
+		return item
+	}
+	password := login("password", "https://www.example.com")
+	password.TemplateUUID = op.TemplatePassword
+
+	fake, err := optest.New(nil, []op.Item{
+		login("host", "https://www.example.com"),
+		login("domain", "https://login.example.com"),
+		login("other", "https://example.org"),
+		password,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	session, err := op.Signin(optest.SigninAddress, optest.Email, []byte(optest.SecretKey), []byte(optest.MasterPassword))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		min  op.MatchRank
+		want []string
+	}{
+		{op.MatchEquivalent, []string{"host", "domain"}},
+		{op.MatchHost, []string{"host"}},
+	}
+	for _, test := range tests {
+		items, u, err := matchLogins(session, "https://www.example.com/login", test.min)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Host != "www.example.com" {
+			t.Errorf("got host %s", u.Host)
+		}
+
+		var got []string
+		for _, item := range items {
+			got = append(got, item.Overview.Title)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: got %v, want %v", test.min, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: got %v, want %v", test.min, got, test.want)
+			}
+		}
+	}
+
+	if _, _, err := matchLogins(session, "file:///etc/passwd", op.MatchHost); err == nil {
+		t.Error("expected an error for a file url")
+	}
+}
@@ -0,0 +1,107 @@
+// Package nativemsg implements the native messaging protocol browsers use to talk to a host
+// application. Messages are json, each preceded by its length as a 32 bit integer in native
+// byte order.
+package nativemsg
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// maxMessageSize is the largest message a browser accepts from a host. Requests from the
+// extension are small, larger ones are rejected too.
+const maxMessageSize = 1024 * 1024
+
+// byteOrder is the native byte order of the platforms 1pass runs on.
+var byteOrder = binary.LittleEndian
+
+// Actions of a request.
+const (
+	ActionQuery = "query" // list the logins matching the url
+	ActionFill  = "fill"  // get the username and password of a login
+)
+
+// Request is a message from the extension.
+type Request struct {
+	ID     int    `json:"id"`
+	Action string `json:"action"`
+	URL    string `json:"url"`            // url of the tab
+	UUID   string `json:"uuid,omitempty"` // login to fill
+}
+
+// Response is the reply to a request with the same id.
+type Response struct {
+	ID       int     `json:"id"`
+	Logins   []Login `json:"logins,omitempty"`
+	Username string  `json:"username,omitempty"`
+	Password string  `json:"password,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// Login is a login item matching the url of a tab.
+type Login struct {
+	UUID     string `json:"uuid"`
+	Title    string `json:"title"`
+	Username string `json:"username"`
+}
+
+// Serve reads requests from r and writes the responses of handle to w until r is closed.
+func Serve(r io.Reader, w io.Writer, handle func(req Request) Response) error {
+	for {
+		var req Request
+		if err := Read(r, &req); err != nil {
+			if errors.Cause(err) == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		resp := handle(req)
+		resp.ID = req.ID
+
+		if err := Write(w, resp); err != nil {
+			return err
+		}
+	}
+}
+
+// Read reads a message into v. io.EOF is returned when r is closed between messages.
+func Read(r io.Reader, v interface{}) error {
+	var size uint32
+	if err := binary.Read(r, byteOrder, &size); err != nil {
+		if err == io.EOF {
+			return errors.WithStack(err)
+		}
+		return errors.Wrap(err, "read message size")
+	}
+	if size > maxMessageSize {
+		return errors.Errorf("message of %d bytes is too large", size)
+	}
+
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return errors.Wrap(err, "read message")
+	}
+
+	return errors.Wrap(json.Unmarshal(msg, v), "decode message")
+}
+
+// Write writes v as a message.
+func Write(w io.Writer, v interface{}) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "encode message")
+	}
+	if len(msg) > maxMessageSize {
+		return errors.Errorf("message of %d bytes is too large", len(msg))
+	}
+
+	if err := binary.Write(w, byteOrder, uint32(len(msg))); err != nil {
+		return errors.Wrap(err, "write message size")
+	}
+	_, err = w.Write(msg)
+	return errors.Wrap(err, "write message")
+}
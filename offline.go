@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/michalnicp/1pass/op"
+	"github.com/pkg/errors"
+)
+
+// offlineCachePath returns the path of the encrypted offline item cache.
+func offlineCachePath() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", errors.Wrap(err, "cache directory")
+	}
+	return filepath.Join(dir, "items.cache"), nil
+}
+
+// syncOffline makes the session write the items it fetches to the offline cache. A cache
+// that can't be decrypted, eg. after changing the master password, is replaced.
+func syncOffline(s *op.Session, masterPassword []byte) error {
+	path, err := offlineCachePath()
+	if err != nil {
+		return err
+	}
+
+	c, err := op.OpenOfflineCache(path, masterPassword)
+	if err != nil {
+		if !os.IsNotExist(err) && errors.Cause(err) != op.ErrWrongPassword {
+			return errors.Wrap(err, "open offline cache")
+		}
+
+		c, err = op.NewOfflineCache(path, masterPassword)
+		if err != nil {
+			return errors.Wrap(err, "create offline cache")
+		}
+	}
+
+	s.SetOfflineCache(c)
+
+	return nil
+}
+
+// signinOffline returns a read only session serving the items from the offline cache.
+func signinOffline(masterPassword []byte) (*op.Session, error) {
+	path, err := offlineCachePath()
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := op.OpenOfflineCache(path, masterPassword)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New("no items available offline")
+		}
+		return nil, errors.Wrap(err, "open offline cache")
+	}
+
+	return op.NewOfflineSession(c)
+}
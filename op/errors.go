@@ -16,6 +16,25 @@ var (
 
 	// ErrInvalidOPConfig represents an invalid op config file.
 	ErrInvalidOPConfig = errors.New("invalid op config")
+
+	// ErrOffline is returned when changing items in an offline session.
+	ErrOffline = errors.New("offline session is read only")
+
+	// ErrNotCached is returned when an item wasn't fetched before going offline.
+	ErrNotCached = errors.New("item is not available offline")
+
+	// ErrLocked is returned when using a locked session.
+	ErrLocked = errors.New("session is locked")
+
+	// ErrWrongPIN is returned when unlocking a session with the wrong pin.
+	ErrWrongPIN = errors.New("wrong pin")
+
+	// ErrPINDisabled is returned when the session can only be unlocked with the master
+	// password.
+	ErrPINDisabled = errors.New("pin unlock disabled, use the master password")
+
+	// ErrWrongPassword is returned when the offline cache can't be decrypted.
+	ErrWrongPassword = errors.New("wrong master password")
 )
 
 var opLogRe = regexp.MustCompile(`^\[LOG\] [\d\/]+ [\d:]+ \(\w+\) (.*)`)
@@ -89,3 +108,17 @@ func IsInvalidCredentialsError(err error) bool {
 	}
 	return false
 }
+
+// IsNetworkError reports whether op failed because it couldn't reach 1Password.
+func IsNetworkError(err error) bool {
+	if operr, ok := errors2.Cause(err).(*Error); ok {
+		message := strings.ToLower(operr.Message)
+		return strings.Contains(message, "dial tcp") ||
+			strings.Contains(message, "no such host") ||
+			strings.Contains(message, "connection refused") ||
+			strings.Contains(message, "network is unreachable") ||
+			strings.Contains(message, "i/o timeout") ||
+			strings.Contains(message, "tls handshake timeout")
+	}
+	return false
+}
@@ -0,0 +1,294 @@
+package op
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"io"
+	"time"
+
+	"github.com/michalnicp/1pass/logging"
+	"github.com/michalnicp/1pass/securemem"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// maxPINAttempts is the number of wrong pins after which the session can only be unlocked
+// with the master password.
+const maxPINAttempts = 3
+
+// lockState is the lock of a session. The master password isn't kept, only a hash of it to
+// check the password when unlocking.
+type lockState struct {
+	locked   bool
+	salt     []byte
+	verifier []byte
+
+	// The session token encrypted with a key derived from the pin. While locked this is the
+	// only copy of the token.
+	pinSalt     []byte
+	pinNonce    [nonceSize]byte
+	pinToken    []byte
+	pinAttempts int
+}
+
+// setMasterPassword remembers a hash of the master password to unlock the session.
+func (s *Session) setMasterPassword(masterPassword []byte) error {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return errors.Wrap(err, "generate salt")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lock.salt = salt
+	s.lock.verifier = argon2.IDKey(masterPassword, salt, 1, 64*1024, 4, 32)
+
+	return nil
+}
+
+// SetPIN enables unlocking the session with the pin instead of the master password. The
+// session token is encrypted with a key derived from the pin, which isn't kept.
+func (s *Session) SetPIN(pin []byte) error {
+	if len(pin) == 0 {
+		return errors.New("pin is empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == "" {
+		return errors.New("session has no token")
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return errors.Wrap(err, "generate salt")
+	}
+
+	var nonce [nonceSize]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return errors.Wrap(err, "generate nonce")
+	}
+
+	key := pinKey(pin, salt)
+	defer securemem.Wipe(key[:])
+
+	token := []byte(s.token)
+	defer securemem.Wipe(token)
+
+	s.lock.pinSalt = salt
+	s.lock.pinNonce = nonce
+	s.lock.pinToken = secretbox.Seal(nil, token, &nonce, &key)
+	s.lock.pinAttempts = 0
+
+	return nil
+}
+
+// HasPIN reports whether the session can be unlocked with a pin. The pin is disabled after
+// too many wrong attempts or when the session token expires.
+func (s *Session) HasPIN() bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lock.pinToken != nil && time.Now().After(s.expiry) {
+		s.clearPIN()
+	}
+
+	return s.lock.pinToken != nil
+}
+
+// clearPIN disables the pin. s.mu must be held.
+func (s *Session) clearPIN() {
+	s.lock.pinSalt = nil
+	s.lock.pinToken = nil
+	s.lock.pinAttempts = 0
+}
+
+// pinKey derives the key encrypting the session token from the pin using argon2id. The cost
+// is higher than for the master password since pins are short.
+func pinKey(pin, salt []byte) [32]byte {
+	var key [32]byte
+	derived := argon2.IDKey(pin, salt, 4, 128*1024, 4, 32)
+	copy(key[:], derived)
+	securemem.Wipe(derived)
+	return key
+}
+
+// Lock locks the session until it is unlocked with the master password or pin. Items cached
+// in memory, including the decrypted offline cache, are removed. The session token is kept,
+// so the session doesn't need to be signed in again, but only encrypted if a pin is set.
+func (s *Session) Lock() {
+	s.mu.Lock()
+	s.lock.locked = true
+	if s.lock.pinToken != nil {
+		s.token = ""
+	}
+	s.mu.Unlock()
+
+	s.clearCache()
+	if s.offlineCache != nil {
+		s.offlineCache.lock()
+	}
+}
+
+// unlockOfflineCache decrypts the offline cache again after unlocking. Sessions served from
+// the cache can't be used without it, other sessions only stop updating it.
+func (s *Session) unlockOfflineCache() error {
+	if s.offlineCache == nil {
+		return nil
+	}
+	err := s.offlineCache.unlock()
+	if err != nil && !s.offline {
+		logging.Error("unlock offline cache", "err", err)
+		return nil
+	}
+	return err
+}
+
+// Signout ends the session with 1Password and forgets the token, pin and cached items. The
+// session can't be used afterwards.
+func (s *Session) Signout() error {
+	s.mu.Lock()
+	token := s.token
+	s.mu.Unlock()
+
+	var err error
+	if !s.offline && token != "" {
+		if _, err = s.command("signout").Output(); err != nil {
+			err = fromExitError(err)
+		}
+	}
+
+	s.mu.Lock()
+	s.token = ""
+	s.expiry = time.Time{}
+	s.lock = lockState{}
+	s.mu.Unlock()
+
+	s.clearCache()
+
+	return err
+}
+
+// Unlock unlocks the session if the master password is correct. The session is signed in
+// again if it has no hash of the master password, eg. sessions created from the op config,
+// or if the token was only kept encrypted with the pin.
+func (s *Session) Unlock(masterPassword []byte) error {
+	s.mu.Lock()
+	salt, verifier, token := s.lock.salt, s.lock.verifier, s.token
+	s.mu.Unlock()
+
+	if verifier != nil {
+		hash := argon2.IDKey(masterPassword, salt, 1, 64*1024, 4, 32)
+		if subtle.ConstantTimeCompare(hash, verifier) != 1 {
+			return errors.WithStack(ErrWrongPassword)
+		}
+	}
+
+	switch {
+	case s.offline:
+		if verifier == nil && !s.offlineCache.checkPassword(masterPassword) {
+			return errors.WithStack(ErrWrongPassword)
+		}
+	case verifier == nil || token == "":
+		signedIn, err := Signin(s.SigninAddress, s.Email, s.SecretKey, masterPassword)
+		if err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		s.shorthand = signedIn.shorthand
+		s.token = signedIn.token
+		s.expiry = signedIn.expiry
+		s.lock.salt = signedIn.lock.salt
+		s.lock.verifier = signedIn.lock.verifier
+
+		// The pin encrypts the previous token.
+		s.clearPIN()
+		s.mu.Unlock()
+	}
+
+	if err := s.unlockOfflineCache(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.lock.locked = false
+	s.lock.pinAttempts = 0
+	s.mu.Unlock()
+
+	return nil
+}
+
+// UnlockPIN unlocks the session if the pin is correct. After too many wrong attempts the pin
+// is disabled and ErrPINDisabled is returned.
+func (s *Session) UnlockPIN(pin []byte) error {
+	if !s.HasPIN() {
+		return errors.WithStack(ErrPINDisabled)
+	}
+
+	s.mu.Lock()
+	salt, nonce, sealed := s.lock.pinSalt, s.lock.pinNonce, s.lock.pinToken
+	s.mu.Unlock()
+
+	key := pinKey(pin, salt)
+	defer securemem.Wipe(key[:])
+
+	token, ok := secretbox.Open(nil, sealed, &nonce, &key)
+	defer securemem.Wipe(token)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !ok {
+		s.lock.pinAttempts++
+		if s.lock.pinAttempts >= maxPINAttempts {
+			s.clearPIN()
+			return errors.WithStack(ErrPINDisabled)
+		}
+		return errors.WithStack(ErrWrongPIN)
+	}
+
+	if err := s.unlockOfflineCache(); err != nil {
+		return err
+	}
+
+	s.token = string(token)
+	s.lock.locked = false
+	s.lock.pinAttempts = 0
+
+	return nil
+}
+
+// Locked reports whether the session is locked.
+func (s *Session) Locked() bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lock.locked
+}
+
+// clearCache removes the items cached in memory and from the search index.
+func (s *Session) clearCache() {
+	if items, ok := s.cache.Get("items"); ok {
+		batch := s.index.NewBatch()
+		for _, item := range items.([]Item) {
+			batch.Delete(item.UUID)
+		}
+		if err := s.index.Batch(batch); err != nil {
+			logging.Error("clear index", "err", err)
+		}
+	}
+
+	s.cache.Flush()
+}
@@ -0,0 +1,203 @@
+package op
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/publicsuffix"
+)
+
+// MatchRank is how well the url of an item matches a url, better matches rank higher.
+type MatchRank int
+
+const (
+	MatchNone       MatchRank = iota
+	MatchEquivalent           // domains in the same equivalent domains group, eg. google.com and youtube.com
+	MatchDomain               // same registrable domain, eg. login.example.com and www.example.com
+	MatchHost                 // same host
+	MatchPath                 // same host and the item path is a prefix of the url path
+)
+
+func (r MatchRank) String() string {
+	switch r {
+	case MatchEquivalent:
+		return "equivalent"
+	case MatchDomain:
+		return "domain"
+	case MatchHost:
+		return "host"
+	case MatchPath:
+		return "path"
+	}
+	return "none"
+}
+
+// Match is an item matching a url.
+type Match struct {
+	Item Item
+	Rank MatchRank
+}
+
+// URLs returns the urls of the item, the main url first.
+func (i *Item) URLs() []string {
+	all := []string{i.Overview.URL}
+	for _, u := range i.Overview.URLs {
+		all = append(all, u.URL)
+	}
+
+	var urls []string
+	seen := make(map[string]bool)
+	for _, u := range all {
+		if u != "" && !seen[u] {
+			urls = append(urls, u)
+			seen[u] = true
+		}
+	}
+	return urls
+}
+
+// MatchURL reports whether one of the item's urls has the host of u. The scheme and path of
+// u are only compared when they are set, in which case the item url path must be a prefix of
+// the path of u.
+func (i *Item) MatchURL(u *url.URL) bool {
+	return i.MatchRank(u, nil) >= MatchHost
+}
+
+// MatchRank returns the best rank of the item's urls for u. Domains in the same group of
+// equivalent domains match each other.
+func (i *Item) MatchRank(u *url.URL, equivalentDomains [][]string) MatchRank {
+	best := MatchNone
+	for _, rawurl := range i.URLs() {
+		v, err := parseItemURL(rawurl)
+		if err != nil {
+			continue
+		}
+		if rank := matchRank(u, v, equivalentDomains); rank > best {
+			best = rank
+		}
+	}
+	return best
+}
+
+// parseItemURL parses an item url, which often lacks the scheme. The scheme of the url
+// returned is empty if so.
+func parseItemURL(rawurl string) (*url.URL, error) {
+	scheme := strings.Contains(rawurl, "://")
+	if !scheme {
+		rawurl = "https://" + rawurl
+	}
+
+	v, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if v.Host == "" {
+		return nil, errors.New("url has no host")
+	}
+	if !scheme {
+		v.Scheme = ""
+	}
+	return v, nil
+}
+
+func matchRank(u, v *url.URL, equivalentDomains [][]string) MatchRank {
+	if u.Scheme != "" && v.Scheme != "" && !strings.EqualFold(u.Scheme, v.Scheme) {
+		return MatchNone
+	}
+	if v.Port() != "" && u.Port() != v.Port() {
+		return MatchNone
+	}
+
+	// An item for a path only matches urls below it.
+	path := strings.Trim(v.Path, "/") != ""
+	if path && u.Path != "" && !hasPathPrefix(u.Path, v.Path) {
+		return MatchNone
+	}
+
+	host, itemHost := strings.ToLower(u.Hostname()), strings.ToLower(v.Hostname())
+	if host == itemHost {
+		if path && u.Path != "" {
+			return MatchPath
+		}
+		return MatchHost
+	}
+
+	domain, itemDomain := registrableDomain(host), registrableDomain(itemHost)
+	if domain == "" || itemDomain == "" {
+		return MatchNone
+	}
+	if domain == itemDomain {
+		return MatchDomain
+	}
+
+	for _, group := range equivalentDomains {
+		if containsFold(group, domain) && containsFold(group, itemDomain) {
+			return MatchEquivalent
+		}
+	}
+
+	return MatchNone
+}
+
+// registrableDomain returns the domain one label below the public suffix of the host, eg.
+// example.co.uk for www.example.co.uk, or an empty string for ip addresses and public
+// suffixes.
+func registrableDomain(host string) string {
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return ""
+	}
+	return domain
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchURL returns the items with a url matching rawurl, best matches first and the most
+// recently updated first among equal matches. Equivalent domains are set in the options.
+func (s *Session) MatchURL(rawurl string) ([]Match, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse url")
+	}
+	if u.Host == "" {
+		if u, err = parseItemURL(rawurl); err != nil {
+			return nil, errors.Wrap(err, "parse url")
+		}
+	}
+
+	items, err := s.ListItems()
+	if err != nil {
+		return nil, err
+	}
+
+	equivalentDomains := currentOptions().EquivalentDomains
+
+	var matches []Match
+	for _, item := range items {
+		if rank := item.MatchRank(u, equivalentDomains); rank > MatchNone {
+			matches = append(matches, Match{Item: item, Rank: rank})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Rank != matches[j].Rank {
+			return matches[i].Rank > matches[j].Rank
+		}
+		return matches[i].Item.UpdatedAt.After(matches[j].Item.UpdatedAt)
+	})
+
+	return matches, nil
+}
@@ -0,0 +1,305 @@
+package op
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/michalnicp/1pass/securemem"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// offlineMagic starts every offline cache file.
+const offlineMagic = "1pass offline v1\n"
+
+const (
+	saltSize  = 16
+	nonceSize = 24
+)
+
+// OfflineCache is a copy of the items and their details kept on disk, encrypted with a key
+// derived from the master password, for when op can't reach 1Password.
+type OfflineCache struct {
+	path string
+	salt []byte
+	key  [32]byte
+
+	// mu guards data and locked. While the session is locked the decrypted data is dropped,
+	// it is read from the file again when unlocking.
+	mu     sync.Mutex
+	data   offlineData
+	locked bool
+}
+
+type offlineData struct {
+	SyncedAt time.Time        `json:"syncedAt"`
+	Email    string           `json:"email"`
+	Vaults   []Vault          `json:"vaults"`
+	Items    []Item           `json:"items"`
+	Details  map[string]*Item `json:"details"`
+}
+
+// NewOfflineCache returns an empty cache that is written to path with a key derived from the
+// master password.
+func NewOfflineCache(path string, masterPassword []byte) (*OfflineCache, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "generate salt")
+	}
+
+	c := OfflineCache{
+		path: path,
+		salt: salt,
+		data: offlineData{Details: make(map[string]*Item)},
+	}
+	c.key = deriveKey(masterPassword, salt)
+
+	return &c, nil
+}
+
+// OpenOfflineCache reads and decrypts the cache at path.
+func OpenOfflineCache(path string, masterPassword []byte) (*OfflineCache, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(b, []byte(offlineMagic)) || len(b) < len(offlineMagic)+saltSize+nonceSize {
+		return nil, errors.New("invalid offline cache")
+	}
+
+	c := OfflineCache{
+		path: path,
+		salt: append([]byte{}, b[len(offlineMagic):len(offlineMagic)+saltSize]...),
+	}
+	c.key = deriveKey(masterPassword, c.salt)
+
+	if err := c.decrypt(b); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// decrypt decrypts the contents of the cache file into c.data. c.mu must be held unless the
+// cache isn't shared yet.
+func (c *OfflineCache) decrypt(b []byte) error {
+	if !bytes.HasPrefix(b, []byte(offlineMagic)) || len(b) < len(offlineMagic)+saltSize+nonceSize {
+		return errors.New("invalid offline cache")
+	}
+	b = b[len(offlineMagic):]
+
+	var nonce [nonceSize]byte
+	copy(nonce[:], b[saltSize:])
+
+	plaintext, ok := secretbox.Open(nil, b[saltSize+nonceSize:], &nonce, &c.key)
+	if !ok {
+		return errors.WithStack(ErrWrongPassword)
+	}
+	defer securemem.Wipe(plaintext)
+
+	var data offlineData
+	if err := json.Unmarshal(plaintext, &data); err != nil {
+		return errors.Wrap(err, "decode offline cache")
+	}
+	if data.Details == nil {
+		data.Details = make(map[string]*Item)
+	}
+	c.data = data
+
+	return nil
+}
+
+// lock drops the decrypted items. Strings can't be wiped, the details are removed and the
+// data zeroed so nothing refers to them.
+func (c *OfflineCache) lock() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id := range c.data.Details {
+		delete(c.data.Details, id)
+	}
+	c.data = offlineData{}
+	c.locked = true
+}
+
+// unlock decrypts the items from the file again. A cache that was never written is empty.
+func (c *OfflineCache) unlock() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.locked {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(c.path)
+	switch {
+	case os.IsNotExist(err):
+		c.data = offlineData{Details: make(map[string]*Item)}
+	case err != nil:
+		return errors.Wrap(err, "read offline cache")
+	default:
+		if err := c.decrypt(b); err != nil {
+			return err
+		}
+	}
+
+	c.locked = false
+	return nil
+}
+
+// deriveKey derives the cache key from the master password using argon2id.
+func deriveKey(masterPassword, salt []byte) [32]byte {
+	var key [32]byte
+	derived := argon2.IDKey(masterPassword, salt, 3, 64*1024, 4, 32)
+	copy(key[:], derived)
+	securemem.Wipe(derived)
+	return key
+}
+
+// checkPassword reports whether the master password derives the key of the cache.
+func (c *OfflineCache) checkPassword(masterPassword []byte) bool {
+	key := deriveKey(masterPassword, c.salt)
+	defer securemem.Wipe(key[:])
+
+	return subtle.ConstantTimeCompare(key[:], c.key[:]) == 1
+}
+
+// SyncedAt returns when the items were last written.
+func (c *OfflineCache) SyncedAt() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.data.SyncedAt
+}
+
+func (c *OfflineCache) vaults() []Vault {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.data.Vaults
+}
+
+func (c *OfflineCache) items() []Item {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.data.Items
+}
+
+func (c *OfflineCache) item(id string) (*Item, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.data.Details[id]
+	return item, ok
+}
+
+// update changes the cached data and writes it to disk. A locked cache isn't changed, the
+// file would be replaced by the partial data.
+func (c *OfflineCache) update(f func(data *offlineData)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.locked {
+		return errors.WithStack(ErrLocked)
+	}
+
+	f(&c.data)
+	c.data.SyncedAt = time.Now()
+
+	// Drop the details of deleted items.
+	ids := make(map[string]bool, len(c.data.Items))
+	for _, item := range c.data.Items {
+		ids[item.UUID] = true
+	}
+	for id := range c.data.Details {
+		if !ids[id] {
+			delete(c.data.Details, id)
+		}
+	}
+
+	return c.write()
+}
+
+func (c *OfflineCache) write() error {
+	plaintext, err := json.Marshal(c.data)
+	if err != nil {
+		return errors.Wrap(err, "encode offline cache")
+	}
+	defer securemem.Wipe(plaintext)
+
+	var nonce [nonceSize]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return errors.Wrap(err, "generate nonce")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(offlineMagic)
+	buf.Write(c.salt)
+	buf.Write(nonce[:])
+	buf.Write(secretbox.Seal(nil, plaintext, &nonce, &c.key))
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return errors.Wrap(err, "create cache directory")
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(c.path), ".offline")
+	if err != nil {
+		return errors.Wrap(err, "create cache file")
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return errors.Wrap(err, "write cache file")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "close cache file")
+	}
+
+	return os.Rename(f.Name(), c.path)
+}
+
+// NewOfflineSession returns a read only session serving the items from the cache.
+func NewOfflineSession(c *OfflineCache) (*Session, error) {
+	c.mu.Lock()
+	email := c.data.Email
+	c.mu.Unlock()
+
+	session, err := NewSession("", email, nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	session.offline = true
+	session.offlineCache = c
+
+	return session, nil
+}
+
+// SetOfflineCache makes the session write the items it fetches to the cache.
+func (s *Session) SetOfflineCache(c *OfflineCache) {
+	s.offlineCache = c
+	c.update(func(data *offlineData) {
+		data.Email = s.Email
+	})
+}
+
+// Offline reports whether the session serves items from the offline cache.
+func (s *Session) Offline() bool {
+	return s != nil && s.offline
+}
+
+// SyncedAt returns when the offline cache was last written, the zero time without a cache.
+func (s *Session) SyncedAt() time.Time {
+	if s == nil || s.offlineCache == nil {
+		return time.Time{}
+	}
+	return s.offlineCache.SyncedAt()
+}
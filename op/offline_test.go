@@ -0,0 +1,65 @@
+package op
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestOfflineLock locks an offline session, which drops the decrypted items, and unlocks it,
+// which decrypts them again.
+func TestOfflineLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "offline")
+
+	masterPassword := []byte("correct horse battery staple")
+
+	c, err := NewOfflineCache(path, masterPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := Item{UUID: "item", Details: &Details{Password: "secret"}}
+	if err := c.update(func(data *offlineData) {
+		data.Items = []Item{item}
+		data.Details[item.UUID] = &item
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := NewOfflineSession(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session.Lock()
+	c.mu.Lock()
+	if c.data.Items != nil || c.data.Details != nil {
+		t.Error("items kept while locked")
+	}
+	c.mu.Unlock()
+
+	// Writing the partial data would replace the items in the file.
+	if err := c.update(func(data *offlineData) {}); err == nil {
+		t.Error("locked cache updated")
+	}
+
+	if err := session.Unlock([]byte("wrong")); err == nil {
+		t.Fatal("unlocked with the wrong password")
+	}
+	if err := session.Unlock(masterPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := session.GetItem(item.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Details.Password != "secret" {
+		t.Fatalf("got password %q", got.Details.Password)
+	}
+}
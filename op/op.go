@@ -3,16 +3,19 @@ package op
 import (
 	"encoding/json"
 	"io"
-	"log"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/blevesearch/bleve"
 	"github.com/blevesearch/bleve/search/query"
 	"github.com/blevesearch/bleve/search/searcher"
+	"github.com/michalnicp/1pass/logging"
+	"github.com/michalnicp/1pass/securemem"
 	cache "github.com/patrickmn/go-cache"
 	"github.com/pkg/errors"
 )
@@ -24,21 +27,79 @@ func init() {
 	searcher.MaxFuzziness = 5
 }
 
+// Options configure new sessions.
+type Options struct {
+
+	// CacheExpiration is how long fetched items are cached.
+	CacheExpiration time.Duration
+
+	// CacheCleanupInterval is how often expired items are removed from the cache.
+	CacheCleanupInterval time.Duration
+
+	// SessionLifetime is how long a session is valid after signing in.
+	SessionLifetime time.Duration
+
+	// Fuzziness is the number of typos allowed in a search term.
+	Fuzziness int
+
+	// EquivalentDomains are groups of registrable domains that share logins, eg. google.com
+	// and youtube.com.
+	EquivalentDomains [][]string
+}
+
+// DefaultOptions are used unless changed with SetOptions.
+var DefaultOptions = Options{
+	CacheExpiration:      15 * time.Minute,
+	CacheCleanupInterval: 5 * time.Minute,
+	SessionLifetime:      30 * time.Minute,
+	Fuzziness:            2,
+}
+
+var (
+	optionsMu sync.Mutex
+	options   = DefaultOptions
+)
+
+// SetOptions changes the options. The cache options only apply to new sessions.
+func SetOptions(opts Options) {
+	optionsMu.Lock()
+	defer optionsMu.Unlock()
+	options = opts
+}
+
+func currentOptions() Options {
+	optionsMu.Lock()
+	defer optionsMu.Unlock()
+	return options
+}
+
 // Session represents a 1Password session.
 type Session struct {
 	SigninAddress string
 	Email         string
-	SecretKey     string
-	Token         string
+	SecretKey     []byte
+	shorthand     string // account shorthand in the op config
+	token         string
 	expiry        time.Time
 
 	cache *cache.Cache
 	index bleve.Index
+
+	// offline is set when the items are served from offlineCache. Otherwise fetched items are
+	// written to offlineCache if set. Both are set before the session is shared.
+	offline      bool
+	offlineCache *OfflineCache
+
+	// mu guards shorthand, token, expiry and lock, which change when the session is locked
+	// and unlocked while other goroutines use it.
+	mu   sync.Mutex
+	lock lockState
 }
 
-// NewSession creates a new 1password session.
-func NewSession(signinAddress, email, secretKey, token string) (*Session, error) {
-	cache := cache.New(15*time.Minute, 5*time.Minute)
+// NewSession creates a new 1password session. The secret key is copied.
+func NewSession(signinAddress, email string, secretKey []byte, token string) (*Session, error) {
+	opts := currentOptions()
+	cache := cache.New(opts.CacheExpiration, opts.CacheCleanupInterval)
 
 	// TODO: Improve search.
 	mapping := bleve.NewIndexMapping()
@@ -50,8 +111,8 @@ func NewSession(signinAddress, email, secretKey, token string) (*Session, error)
 	session := Session{
 		SigninAddress: signinAddress,
 		Email:         email,
-		SecretKey:     secretKey,
-		Token:         token,
+		SecretKey:     append([]byte(nil), secretKey...),
+		token:         token,
 		cache:         cache,
 		index:         index,
 	}
@@ -70,10 +131,11 @@ func NewSessionFromConfig() (*Session, error) {
 			name := "OP_SESSION_" + cfg.LatestSignin
 			token := os.Getenv(name)
 
-			session, err := NewSession(account.URL, account.Email, account.AccountKey, token)
+			session, err := NewSession(account.URL, account.Email, []byte(account.AccountKey), token)
 			if err != nil {
 				return nil, errors.Wrap(err, "create session")
 			}
+			session.shorthand = account.Shorthand
 
 			return session, nil
 		}
@@ -82,13 +144,24 @@ func NewSessionFromConfig() (*Session, error) {
 	return nil, errors.WithStack(ErrInvalidOPConfig)
 }
 
-// Signin signs in with 1Password and returns a session.
-func Signin(signinAddress, email, secretKey, masterPassword string) (*Session, error) {
+// Signin signs in with 1Password and returns a session. The master password isn't kept and
+// the session keeps a copy of the secret key, the caller should wipe both.
+func Signin(signinAddress, email string, secretKey, masterPassword []byte) (*Session, error) {
 	if signinAddress == "" {
 		signinAddress = defaultSigninAddress
 	}
 
-	cmd := exec.Command("op", "signin", signinAddress, email, secretKey, masterPassword, "--output=raw")
+	// The secret key and master password are written to stdin, arguments are visible to
+	// other users. op only asks for the secret key of accounts it doesn't know.
+	shorthand, known := accountShorthand(signinAddress, email)
+
+	var cmd *exec.Cmd
+	if known {
+		cmd = exec.Command("op", "signin", shorthand, "--output=raw")
+	} else {
+		cmd = exec.Command("op", "signin", signinAddress, email, "--shorthand="+shorthand, "--output=raw")
+	}
+
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return nil, errors.Wrap(err, "open stdin pipe")
@@ -96,7 +169,12 @@ func Signin(signinAddress, email, secretKey, masterPassword string) (*Session, e
 
 	go func() {
 		defer stdin.Close()
-		io.WriteString(stdin, masterPassword)
+		if !known {
+			stdin.Write(secretKey)
+			io.WriteString(stdin, "\n")
+		}
+		stdin.Write(masterPassword)
+		io.WriteString(stdin, "\n")
 	}()
 
 	out, err := cmd.Output()
@@ -109,57 +187,193 @@ func Signin(signinAddress, email, secretKey, masterPassword string) (*Session, e
 	if err != nil {
 		return nil, errors.Wrap(err, "create session")
 	}
+	session.shorthand = shorthand
 
 	session.refresh()
 
+	if err := session.setMasterPassword(masterPassword); err != nil {
+		return nil, err
+	}
+
 	return session, nil
 }
 
+// accountShorthand returns the shorthand of the account in the op config, or a new one
+// derived from the sign in address if op doesn't know the account.
+func accountShorthand(signinAddress, email string) (string, bool) {
+	if cfg, err := ReadConfig(); err == nil {
+		for _, account := range cfg.Accounts {
+			if account.Email == email && strings.TrimPrefix(account.URL, "https://") == strings.TrimPrefix(signinAddress, "https://") {
+				return account.Shorthand, true
+			}
+		}
+	}
+
+	host := strings.TrimPrefix(signinAddress, "https://")
+	return strings.SplitN(host, ".", 2)[0], false
+}
+
+// command returns an op command using the session. The session token is passed in the
+// environment instead of the arguments, which are visible to other users.
+func (s *Session) command(args ...string) *exec.Cmd {
+	s.mu.Lock()
+	shorthand, token := s.shorthand, s.token
+	s.mu.Unlock()
+
+	cmd := exec.Command("op", append(args, "--account="+shorthand)...)
+	cmd.Env = append(os.Environ(), "OP_SESSION_"+shorthand+"="+token)
+	return cmd
+}
+
 func (s *Session) refresh() error {
-	if s.Token == "" {
+	if !s.HasToken() {
 		return errors.New("session token is empty")
 	}
 
 	// Refresh the session by calling op. This command is the least expensive to call.
-	cmd := exec.Command("op", "get", "account", "--session="+s.Token)
+	cmd := s.command("get", "account")
 
 	if _, err := cmd.Output(); err != nil {
 		return fromExitError(err)
 	}
 
-	s.expiry = time.Now().Add(30 * time.Minute)
+	expiry := time.Now().Add(currentOptions().SessionLifetime)
+
+	s.mu.Lock()
+	s.expiry = expiry
+	s.mu.Unlock()
 
 	return nil
 }
 
+// HasToken reports whether the session has a token. The token is only kept encrypted while
+// the session is locked with a pin.
+func (s *Session) HasToken() bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.token != ""
+}
+
+// SetShorthand sets the account shorthand in the op config, which names the session
+// environment variable op reads the token from.
+func (s *Session) SetShorthand(shorthand string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.shorthand = shorthand
+}
+
 func (s *Session) Valid() bool {
-	if s == nil || s.Token == "" {
+	if s.Locked() {
 		return false
 	}
-	if time.Now().After(s.expiry) {
+	if s.Offline() {
+		return true
+	}
+	if s == nil {
 		return false
 	}
-	return true
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.token != "" && !time.Now().After(s.expiry)
+}
+
+// Item templates.
+const (
+	TemplateLogin    = "001"
+	TemplatePassword = "005"
+	TemplateDocument = "006"
+	TemplateSSHKey   = "114"
+)
+
+// Categories maps item templates to the category names accepted by op create item.
+var Categories = map[string]string{
+	"001": "Login",
+	"002": "Credit Card",
+	"003": "Secure Note",
+	"004": "Identity",
+	"005": "Password",
+	"100": "Software License",
+	"101": "Bank Account",
+	"102": "Database",
+	"103": "Driver License",
+	"104": "Outdoor License",
+	"105": "Membership",
+	"106": "Passport",
+	"107": "Reward Program",
+	"108": "Social Security Number",
+	"109": "Wireless Router",
+	"110": "Server",
+	"111": "Email Account",
 }
 
 type Item struct {
-	UUID     string `json:"uuid"`
-	Overview struct {
-		Title string `json:"title"`
-		AInfo string `json:"ainfo"`
+	UUID         string    `json:"uuid"`
+	TemplateUUID string    `json:"templateUuid"`
+	VaultUUID    string    `json:"vaultUuid"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+	Overview     struct {
+		Title string    `json:"title"`
+		AInfo string    `json:"ainfo"`
+		URL   string    `json:"url"`
+		URLs  []ItemURL `json:"URLs"`
+		Tags  []string  `json:"tags"`
+		PS    int       `json:"ps"`  // password strength, 0 to 100
+		PBE   float64   `json:"pbe"` // password bits of entropy
 	} `json:"overview"`
 	Details *Details `json:"details,omitempty"` // omitted when listing items
 }
 
+type ItemURL struct {
+	URL string `json:"u"`
+}
+
+// HasTag reports whether the item is tagged with tag.
+func (i *Item) HasTag(tag string) bool {
+	for _, t := range i.Overview.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
 type Details struct {
 	Fields   []DetailsField `json:"fields"`
 	Notes    string         `json:"notesPlain"`
+	Password string         `json:"password"` // set for password items
 	Sections []Section      `json:"sections"`
 }
 
+// hasPathPrefix reports whether path begins with the path segments of prefix.
+func hasPathPrefix(path, prefix string) bool {
+	path = strings.Trim(path, "/")
+	prefix = strings.Trim(prefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// Value returns the value of the field with the designation, eg. username or password.
+func (d *Details) Value(designation string) string {
+	for _, field := range d.Fields {
+		if field.Designation == designation {
+			return field.Value
+		}
+	}
+	return ""
+}
+
 type DetailsField struct {
 	Designation string `json:"designation"`
 	Name        string `json:"name"`
+	Type        string `json:"type"`
 	Value       string `json:"value"`
 }
 
@@ -171,27 +385,83 @@ type Section struct {
 
 type SectionField struct {
 	Type  string `json:"k"`
+	Name  string `json:"n"`
 	Title string `json:"t"`
 	Value string `json:"v"`
 }
 
-func (s *Session) ListItems() ([]Item, error) {
-	if items, ok := s.cache.Get("items"); ok {
-		return items.([]Item), nil
+type Vault struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+func (s *Session) ListVaults() ([]Vault, error) {
+	if s.Locked() {
+		return nil, errors.WithStack(ErrLocked)
+	}
+
+	if s.offline {
+		return s.offlineCache.vaults(), nil
 	}
 
-	cmd := exec.Command("op", "list", "items", "--session="+s.Token)
+	if vaults, ok := s.cache.Get("vaults"); ok {
+		return vaults.([]Vault), nil
+	}
+
+	cmd := s.command("list", "vaults")
 
 	out, err := cmd.Output()
 	if err != nil {
 		return nil, fromExitError(err)
 	}
 
-	var items []Item
-	if err := json.Unmarshal(out, &items); err != nil {
+	var vaults []Vault
+	if err := json.Unmarshal(out, &vaults); err != nil {
 		return nil, err
 	}
 
+	s.cache.SetDefault("vaults", vaults)
+
+	if s.offlineCache != nil {
+		if err := s.offlineCache.update(func(data *offlineData) { data.Vaults = vaults }); err != nil {
+			logging.Error("update offline cache", "err", err)
+		}
+	}
+
+	return vaults, nil
+}
+
+func (s *Session) ListItems() ([]Item, error) {
+	if s.Locked() {
+		return nil, errors.WithStack(ErrLocked)
+	}
+
+	if items, ok := s.cache.Get("items"); ok {
+		return items.([]Item), nil
+	}
+
+	var items []Item
+	if s.offline {
+		items = s.offlineCache.items()
+	} else {
+		cmd := s.command("list", "items")
+
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fromExitError(err)
+		}
+
+		if err := json.Unmarshal(out, &items); err != nil {
+			return nil, err
+		}
+
+		if s.offlineCache != nil {
+			if err := s.offlineCache.update(func(data *offlineData) { data.Items = items }); err != nil {
+				logging.Error("update offline cache", "err", err)
+			}
+		}
+	}
+
 	// Sort the items by uuid.
 	sort.Slice(items, func(i, j int) bool {
 		return items[i].UUID < items[j].UUID
@@ -212,7 +482,7 @@ func (s *Session) ListItems() ([]Item, error) {
 		return nil, errors.Wrap(err, "index items")
 	}
 
-	log.Printf("index took %s", time.Since(t))
+	logging.Debug("index items", "count", len(items), "took", time.Since(t))
 
 	return items, nil
 }
@@ -227,11 +497,24 @@ func (s *Session) SearchItems(queryStr string) ([]Item, error) {
 		return items, nil
 	}
 
+	// A url finds the items of the site instead.
+	if strings.Contains(queryStr, "://") {
+		matches, err := s.MatchURL(queryStr)
+		if err != nil {
+			return nil, err
+		}
+		results := make([]Item, 0, len(matches))
+		for _, match := range matches {
+			results = append(results, match.Item)
+		}
+		return results, nil
+	}
+
 	// TODO: Improve search.
 	var disjuncts []query.Query
 	{
 		query := bleve.NewFuzzyQuery(queryStr)
-		query.SetFuzziness(2)
+		query.SetFuzziness(currentOptions().Fuzziness)
 		query.SetBoost(1)
 		disjuncts = append(disjuncts, query)
 	}
@@ -249,23 +532,40 @@ func (s *Session) SearchItems(queryStr string) ([]Item, error) {
 		return nil, errors.Wrap(err, "search index")
 	}
 
-	results := make([]Item, len(searchResults.Hits))
-	for i, match := range searchResults.Hits {
+	results := make([]Item, 0, len(searchResults.Hits))
+	for _, match := range searchResults.Hits {
 		j := sort.Search(len(items), func(k int) bool {
 			return items[k].UUID >= match.ID
 		})
-		results[i] = items[j]
+
+		// The index may still contain items that have since been deleted.
+		if j == len(items) || items[j].UUID != match.ID {
+			continue
+		}
+		results = append(results, items[j])
 	}
 
 	return results, nil
 }
 
 func (s *Session) GetItem(id string) (*Item, error) {
+	if s.Locked() {
+		return nil, errors.WithStack(ErrLocked)
+	}
+
 	if item, ok := s.cache.Get("item:" + id); ok {
 		return item.(*Item), nil
 	}
 
-	cmd := exec.Command("op", "get", "item", id, "--session="+s.Token)
+	if s.offline {
+		item, ok := s.offlineCache.item(id)
+		if !ok {
+			return nil, errors.WithStack(ErrNotCached)
+		}
+		return item, nil
+	}
+
+	cmd := s.command("get", "item", id)
 
 	out, err := cmd.Output()
 	if err != nil {
@@ -280,5 +580,170 @@ func (s *Session) GetItem(id string) (*Item, error) {
 	// Store the item in the cache using default expiry.
 	s.cache.SetDefault("item:"+id, &item)
 
+	if s.offlineCache != nil {
+		if err := s.offlineCache.update(func(data *offlineData) { data.Details[item.UUID] = &item }); err != nil {
+			logging.Error("update offline cache", "err", err)
+		}
+	}
+
 	return &item, nil
 }
+
+// GetItems returns the items with their details. Items that are not cached are fetched at
+// most once per interval to avoid being rate limited.
+func (s *Session) GetItems(items []Item, interval time.Duration) ([]*Item, error) {
+	var last time.Time
+
+	details := make([]*Item, len(items))
+	for i, item := range items {
+		if cached, ok := s.cache.Get("item:" + item.UUID); ok {
+			details[i] = cached.(*Item)
+			continue
+		}
+
+		if wait := interval - time.Since(last); wait > 0 {
+			time.Sleep(wait)
+		}
+		last = time.Now()
+
+		fetched, err := s.GetItem(item.UUID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get item %s", item.UUID)
+		}
+		details[i] = fetched
+	}
+
+	return details, nil
+}
+
+// GetDocument returns the file contents of a document item. Documents are not cached, they
+// may contain private keys the caller should wipe after use.
+func (s *Session) GetDocument(id string) ([]byte, error) {
+	if s.Locked() {
+		return nil, errors.WithStack(ErrLocked)
+	}
+
+	if s.offline {
+		return nil, errors.WithStack(ErrOffline)
+	}
+
+	cmd := s.command("get", "document", id)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fromExitError(err)
+	}
+
+	return out, nil
+}
+
+// CreateItem creates a new item using the template category, eg. Login. The title, url and
+// tags are read from the item overview.
+func (s *Session) CreateItem(category, vault string, item *Item) (*Item, error) {
+	if s.offline {
+		return nil, errors.WithStack(ErrOffline)
+	}
+
+	details, err := json.Marshal(item.Details)
+	if err != nil {
+		return nil, errors.Wrap(err, "encode item details")
+	}
+	defer securemem.Wipe(details)
+
+	// The details are passed in a template file only the user can read, arguments are
+	// visible to other users.
+	template, err := writeTemplate(details)
+	if err != nil {
+		return nil, err
+	}
+	defer removeTemplate(template, len(details))
+
+	args := []string{
+		"create", "item", category,
+		"--template=" + template,
+		"--title=" + item.Overview.Title,
+	}
+	if item.Overview.URL != "" {
+		args = append(args, "--url="+item.Overview.URL)
+	}
+	if len(item.Overview.Tags) > 0 {
+		args = append(args, "--tags="+strings.Join(item.Overview.Tags, ","))
+	}
+	if vault != "" {
+		args = append(args, "--vault="+vault)
+	}
+
+	cmd := s.command(args...)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fromExitError(err)
+	}
+
+	var created Item
+	if err := json.Unmarshal(out, &created); err != nil {
+		return nil, err
+	}
+
+	// The item list is stale now, fetch it again on the next call.
+	s.cache.Delete("items")
+
+	return &created, nil
+}
+
+// writeTemplate writes the item details to a new file readable only by the user and returns
+// its path. The file is created in XDG_RUNTIME_DIR, which is kept in memory, if set.
+func writeTemplate(details []byte) (string, error) {
+	f, err := ioutil.TempFile(os.Getenv("XDG_RUNTIME_DIR"), "1pass-item")
+	if err != nil {
+		return "", errors.Wrap(err, "create template file")
+	}
+	defer f.Close()
+
+	if err := f.Chmod(0600); err != nil {
+		os.Remove(f.Name())
+		return "", errors.Wrap(err, "create template file")
+	}
+
+	if _, err := f.Write(details); err != nil {
+		os.Remove(f.Name())
+		return "", errors.Wrap(err, "write template file")
+	}
+
+	return f.Name(), nil
+}
+
+// removeTemplate overwrites the template file with zeros and removes it.
+func removeTemplate(path string, size int) {
+	if f, err := os.OpenFile(path, os.O_WRONLY, 0); err == nil {
+		f.Write(make([]byte, size))
+		f.Sync()
+		f.Close()
+	}
+
+	if err := os.Remove(path); err != nil {
+		logging.Error("remove template file", "err", err)
+	}
+}
+
+// DeleteItem deletes the item with the uuid.
+func (s *Session) DeleteItem(id string) error {
+	if s.offline {
+		return errors.WithStack(ErrOffline)
+	}
+
+	cmd := s.command("delete", "item", id)
+
+	if _, err := cmd.Output(); err != nil {
+		return fromExitError(err)
+	}
+
+	s.cache.Delete("items")
+	s.cache.Delete("item:" + id)
+
+	if err := s.index.Delete(id); err != nil {
+		return errors.Wrap(err, "delete item from index")
+	}
+
+	return nil
+}
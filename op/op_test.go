@@ -0,0 +1,201 @@
+package op_test
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/michalnicp/1pass/op"
+	"github.com/michalnicp/1pass/op/optest"
+)
+
+func TestMain(m *testing.M) {
+	optest.Main(m)
+}
+
+// TestSecretsNotInArgs checks that secrets are only passed to op on stdin and in files, never
+// in the arguments or, except for the session token, the environment.
+func TestSecretsNotInArgs(t *testing.T) {
+	fake, err := optest.New(nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	session, err := op.Signin(optest.SigninAddress, optest.Email, []byte(optest.SecretKey), []byte(optest.MasterPassword))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const password = "item-password-1234"
+	item := op.Item{Details: &op.Details{Fields: []op.DetailsField{
+		{Designation: "username", Value: "user"},
+		{Designation: "password", Value: password},
+	}}}
+	item.Overview.Title = "example.com"
+
+	if _, err := session.CreateItem("Login", "", &item); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := fake.Items()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Details.Value("password") != password {
+		t.Fatalf("item not created: %+v", items)
+	}
+
+	calls, err := fake.Calls()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var template string
+	for _, call := range calls {
+		for _, arg := range call.Args {
+			if containsSecret(arg, password) {
+				t.Errorf("argument %q of op %v contains a secret", arg, call.Args)
+			}
+			if strings.HasPrefix(arg, "--template=") {
+				template = strings.TrimPrefix(arg, "--template=")
+			}
+		}
+
+		for _, env := range call.Env {
+			if strings.HasPrefix(env, "OP_SESSION_"+optest.Shorthand+"=") {
+				continue
+			}
+			if containsSecret(env, password) {
+				t.Errorf("environment %q of op %v contains a secret", env, call.Args)
+			}
+		}
+	}
+
+	if calls[0].Stdin != optest.SecretKey+"\n"+optest.MasterPassword+"\n" {
+		t.Errorf("signin stdin got %q", calls[0].Stdin)
+	}
+
+	if template == "" {
+		t.Fatal("item created without a template")
+	}
+	if _, err := os.Stat(template); !os.IsNotExist(err) {
+		t.Errorf("template %s not removed: %v", template, err)
+	}
+}
+
+// containsSecret reports whether s contains a secret of the fake op or the password, as is
+// or base64 encoded.
+func containsSecret(s, password string) bool {
+	values := []string{s}
+	if i := strings.IndexByte(s, '='); i >= 0 {
+		values = append(values, s[i+1:])
+	}
+	for _, v := range values[:len(values):len(values)] {
+		for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+			if decoded, err := encoding.DecodeString(v); err == nil {
+				values = append(values, string(decoded))
+			}
+		}
+	}
+
+	for _, v := range values {
+		for _, secret := range []string{optest.MasterPassword, optest.SecretKey, optest.Token, password} {
+			if strings.Contains(v, secret) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestSigninWrongPassword(t *testing.T) {
+	fake, err := optest.New(nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	if _, err := op.Signin(optest.SigninAddress, optest.Email, []byte(optest.SecretKey), []byte("wrong")); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+// TestConcurrentUse locks and unlocks, replacing the session token, while other goroutines
+// search and get items, as the ui does while the agents and services use the session. Run
+// with -race.
+func TestConcurrentUse(t *testing.T) {
+	var items []op.Item
+	for _, title := range []string{"one", "two", "three"} {
+		item := op.Item{TemplateUUID: op.TemplateLogin, Details: &op.Details{}}
+		item.Overview.Title = title
+		items = append(items, item)
+	}
+
+	fake, err := optest.New(nil, items, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	// A session from the op config has no hash of the master password, the first unlock
+	// signs in again and replaces the token.
+	session, err := fake.Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				session.Valid()
+				session.HasPIN()
+				session.HasToken()
+
+				// Errors are expected while locked.
+				if items, err := session.ListItems(); err == nil && len(items) > 0 {
+					session.GetItem(items[0].UUID)
+				}
+				session.SearchItems("https://example.com")
+			}
+		}()
+	}
+
+	session.Lock()
+	if err := session.Unlock([]byte(optest.MasterPassword)); err != nil {
+		t.Error(err)
+	}
+
+	// With a pin the token is removed when locking and decrypted when unlocking.
+	if err := session.SetPIN([]byte("1234")); err != nil {
+		t.Error(err)
+	}
+	for i := 0; i < 3; i++ {
+		session.Lock()
+		if err := session.UnlockPIN([]byte("1234")); err != nil {
+			t.Error(err)
+		}
+	}
+
+	close(done)
+	wg.Wait()
+
+	if !session.Valid() {
+		t.Fatal("session not valid after unlocking")
+	}
+	if _, err := session.ListItems(); err != nil {
+		t.Fatal(err)
+	}
+}
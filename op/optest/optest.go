@@ -0,0 +1,377 @@
+// Package optest provides a fake op for testing code that runs op. The test binary is
+// linked as op in a temporary directory that is added to PATH. When run as op it serves the
+// commands from a json file instead of 1Password and records how it was called.
+package optest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/michalnicp/1pass/op"
+	"github.com/pkg/errors"
+)
+
+// Account of the fake op.
+const (
+	SigninAddress  = "example.1password.com"
+	Email          = "user@example.com"
+	SecretKey      = "A3-ABCDEF-GHIJKL-MNOPQ-RSTUV-WXYZ2-34567"
+	MasterPassword = "correct horse battery staple"
+	Shorthand      = "example"
+	Token          = "optest-session-token"
+)
+
+// dbEnv names the variable holding the path of the json file the fake op serves.
+const dbEnv = "OPTEST_DB"
+
+// Call is a run of the fake op.
+type Call struct {
+	Args  []string `json:"args"`
+	Env   []string `json:"env"`
+	Stdin string   `json:"stdin"`
+}
+
+// Document is the file of a document item.
+type Document struct {
+	UUID string `json:"uuid"`
+	Data []byte `json:"data"`
+}
+
+type db struct {
+	Vaults    []op.Vault `json:"vaults"`
+	Items     []op.Item  `json:"items"`
+	Documents []Document `json:"documents"`
+	Calls     []Call     `json:"calls"`
+}
+
+// Main runs the fake op if the test binary was started as op and the tests otherwise. It is
+// called from TestMain.
+func Main(m *testing.M) {
+	if filepath.Base(os.Args[0]) == "op" {
+		if err := run(os.Args[1:]); err != nil {
+			// The format of op's errors, see op.fromExitError.
+			fmt.Fprintf(os.Stderr, "[LOG] %s (ERROR) %v\n", time.Now().Format("2006/01/02 15:04:05"), err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+// Fake is an op serving the vaults, items and documents it was created with.
+type Fake struct {
+	dir  string
+	path string // PATH before the fake was added
+}
+
+// New links the test binary as op in a temporary directory and adds it to PATH. Items
+// without a uuid or vault are given one. Uuids are alphanumeric like those of 1Password, they
+// are used in D-Bus object paths. Close must be called to restore PATH.
+func New(vaults []op.Vault, items []op.Item, documents []Document) (*Fake, error) {
+	if len(vaults) == 0 {
+		vaults = []op.Vault{{UUID: "vaultprivate", Name: "Private"}}
+	}
+	for i := range items {
+		if items[i].UUID == "" {
+			items[i].UUID = fmt.Sprintf("item%d", i)
+		}
+		if items[i].VaultUUID == "" {
+			items[i].VaultUUID = vaults[0].UUID
+		}
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, errors.Wrap(err, "test executable")
+	}
+
+	dir, err := ioutil.TempDir("", "optest")
+	if err != nil {
+		return nil, errors.Wrap(err, "create directory")
+	}
+
+	f := &Fake{dir: dir, path: os.Getenv("PATH")}
+
+	if err := os.Symlink(exe, filepath.Join(dir, "op")); err != nil {
+		os.RemoveAll(dir)
+		return nil, errors.Wrap(err, "link op")
+	}
+
+	if err := writeDB(f.dbPath(), &db{Vaults: vaults, Items: items, Documents: documents}); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+f.path)
+	os.Setenv(dbEnv, f.dbPath())
+
+	return f, nil
+}
+
+// Close removes the fake op from PATH.
+func (f *Fake) Close() error {
+	os.Setenv("PATH", f.path)
+	os.Unsetenv(dbEnv)
+	return os.RemoveAll(f.dir)
+}
+
+func (f *Fake) dbPath() string {
+	return filepath.Join(f.dir, "db.json")
+}
+
+// Session returns a session signed in to the fake op.
+func (f *Fake) Session() (*op.Session, error) {
+	session, err := op.NewSession(SigninAddress, Email, []byte(SecretKey), Token)
+	if err != nil {
+		return nil, err
+	}
+	session.SetShorthand(Shorthand)
+	return session, nil
+}
+
+// Items returns the items of the fake op with their details.
+func (f *Fake) Items() ([]op.Item, error) {
+	var items []op.Item
+	err := update(f.dbPath(), func(d *db) error {
+		items = d.Items
+		return nil
+	})
+	return items, err
+}
+
+// Calls returns the runs of the fake op, oldest first.
+func (f *Fake) Calls() ([]Call, error) {
+	var calls []Call
+	err := update(f.dbPath(), func(d *db) error {
+		calls = d.Calls
+		return nil
+	})
+	return calls, err
+}
+
+// run serves an op command.
+func run(args []string) error {
+	var stdin []byte
+	if len(args) > 0 && args[0] == "signin" {
+		var err error
+		if stdin, err = readSignin(args); err != nil {
+			return err
+		}
+	}
+
+	return update(os.Getenv(dbEnv), func(d *db) error {
+		d.Calls = append(d.Calls, Call{Args: args, Env: os.Environ(), Stdin: string(stdin)})
+
+		var positional []string
+		flags := make(map[string]string)
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "--") {
+				kv := strings.SplitN(arg[2:], "=", 2)
+				if len(kv) == 1 {
+					kv = append(kv, "")
+				}
+				flags[kv[0]] = kv[1]
+				continue
+			}
+			positional = append(positional, arg)
+		}
+
+		if len(positional) > 0 && positional[0] == "signin" {
+			lines := strings.Split(strings.TrimSuffix(string(stdin), "\n"), "\n")
+			if lines[len(lines)-1] != MasterPassword {
+				return errors.New("401: Authentication required.")
+			}
+			fmt.Println(Token)
+			return nil
+		}
+
+		if os.Getenv("OP_SESSION_"+flags["account"]) != Token {
+			return errors.New("You are not currently signed in.")
+		}
+
+		return serve(d, positional, flags)
+	})
+}
+
+// readSignin reads the secret key, if the account is new, and the master password from
+// stdin.
+func readSignin(args []string) ([]byte, error) {
+	lines := 1
+	if len(args) > 2 && !strings.HasPrefix(args[2], "--") {
+		lines = 2
+	}
+
+	var stdin []byte
+	r := bufio.NewReader(os.Stdin)
+	for i := 0; i < lines; i++ {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return nil, errors.Wrap(err, "read stdin")
+		}
+		stdin = append(stdin, line...)
+	}
+	return stdin, nil
+}
+
+func serve(d *db, args []string, flags map[string]string) error {
+	command := strings.Join(args[:min(len(args), 2)], " ")
+
+	switch {
+	case command == "get account":
+		fmt.Println(`{"uuid":"account"}`)
+
+	case command == "signout":
+
+	case command == "list vaults":
+		return json.NewEncoder(os.Stdout).Encode(d.Vaults)
+
+	case command == "list items":
+		items := make([]op.Item, len(d.Items))
+		for i, item := range d.Items {
+			item.Details = nil
+			items[i] = item
+		}
+		return json.NewEncoder(os.Stdout).Encode(items)
+
+	case command == "get item" && len(args) == 3:
+		for _, item := range d.Items {
+			if item.UUID == args[2] {
+				return json.NewEncoder(os.Stdout).Encode(item)
+			}
+		}
+		return errors.Errorf("Item %s not found.", args[2])
+
+	case command == "get document" && len(args) == 3:
+		for _, document := range d.Documents {
+			if document.UUID == args[2] {
+				_, err := os.Stdout.Write(document.Data)
+				return err
+			}
+		}
+		return errors.Errorf("Document %s not found.", args[2])
+
+	case command == "create item" && len(args) == 3:
+		item, err := createItem(d, args[2], flags)
+		if err != nil {
+			return err
+		}
+		d.Items = append(d.Items, *item)
+
+		item.Details = nil
+		return json.NewEncoder(os.Stdout).Encode(item)
+
+	case command == "delete item" && len(args) == 3:
+		for i, item := range d.Items {
+			if item.UUID == args[2] {
+				d.Items = append(d.Items[:i], d.Items[i+1:]...)
+				return nil
+			}
+		}
+		return errors.Errorf("Item %s not found.", args[2])
+
+	default:
+		return errors.Errorf("unknown command %q", strings.Join(args, " "))
+	}
+
+	return nil
+}
+
+func createItem(d *db, category string, flags map[string]string) (*op.Item, error) {
+	var item op.Item
+	for template, name := range op.Categories {
+		if name == category {
+			item.TemplateUUID = template
+		}
+	}
+	if item.TemplateUUID == "" {
+		return nil, errors.Errorf("Unknown category %q.", category)
+	}
+
+	data, err := ioutil.ReadFile(flags["template"])
+	if err != nil {
+		return nil, errors.Wrap(err, "read template")
+	}
+	if err := json.Unmarshal(data, &item.Details); err != nil {
+		return nil, errors.Wrap(err, "parse item details")
+	}
+
+	item.VaultUUID = d.Vaults[0].UUID
+	if vault, ok := flags["vault"]; ok {
+		item.VaultUUID = ""
+		for _, v := range d.Vaults {
+			if v.UUID == vault || v.Name == vault {
+				item.VaultUUID = v.UUID
+			}
+		}
+		if item.VaultUUID == "" {
+			return nil, errors.Errorf("Vault %s not found.", vault)
+		}
+	}
+
+	item.UUID = fmt.Sprintf("item%dn%d", len(d.Items), time.Now().UnixNano())
+	item.CreatedAt = time.Now().UTC()
+	item.UpdatedAt = item.CreatedAt
+	item.Overview.Title = flags["title"]
+	item.Overview.URL = flags["url"]
+	if item.Overview.URL != "" {
+		item.Overview.URLs = []op.ItemURL{{URL: item.Overview.URL}}
+	}
+	if tags := flags["tags"]; tags != "" {
+		item.Overview.Tags = strings.Split(tags, ",")
+	}
+	if item.Details != nil && item.TemplateUUID == op.TemplateLogin {
+		item.Overview.AInfo = item.Details.Value("username")
+	}
+
+	return &item, nil
+}
+
+// update calls f with the contents of the json file and writes them back. The file is
+// locked since op may be run concurrently.
+func update(path string, f func(*db) error) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0600)
+	if err != nil {
+		return errors.Wrap(err, "open db")
+	}
+	defer file.Close()
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return errors.Wrap(err, "lock db")
+	}
+
+	var d db
+	if err := json.NewDecoder(file).Decode(&d); err != nil {
+		return errors.Wrap(err, "read db")
+	}
+
+	if err := f(&d); err != nil {
+		return err
+	}
+
+	return writeDB(path, &d)
+}
+
+// writeDB writes the json file in place, so the lock held on it is kept.
+func writeDB(path string, d *db) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return errors.Wrap(err, "encode db")
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/michalnicp/1pass/secretref"
+	"github.com/pkg/errors"
+)
+
+// run runs a command with secret references in its environment replaced by their values.
+//
+//  1pass run --env-file .env.tpl -- make deploy
+//
+// The env file contains KEY=value lines where values of the form op://vault/item/field are
+// resolved. References in the current environment are resolved as well. Secret values are
+// masked in the output of the command.
+func run(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	envFile := flags.String("env-file", "", "file with environment variables to set")
+	noMasking := flags.Bool("no-masking", false, "don't mask secrets in the command output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	env := os.Environ()
+	if *envFile != "" {
+		vars, err := readEnvFile(*envFile)
+		if err != nil {
+			return errors.Wrap(err, "read env file")
+		}
+		env = append(env, vars...)
+	}
+
+	session, err := cliSession()
+	if err != nil {
+		return err
+	}
+
+	// Find the references in the environment.
+	refs := make(map[int]secretref.Reference)
+	var all []secretref.Reference
+	for i, kv := range env {
+		j := strings.IndexByte(kv, '=')
+		if j < 0 || !secretref.IsReference(kv[j+1:]) {
+			continue
+		}
+
+		ref, err := secretref.Parse(kv[j+1:])
+		if err != nil {
+			return errors.Wrap(err, kv[:j])
+		}
+
+		refs[i] = ref
+		all = append(all, ref)
+	}
+
+	openAuditLog()
+	defer auditLog.Close()
+
+	resolver := secretref.Resolver{
+		Session: session,
+		Reveal:  revealFunc("run"),
+	}
+
+	values, err := resolver.ResolveAll(all)
+	if err != nil {
+		return err
+	}
+
+	var secrets []string
+	for i, ref := range refs {
+		j := strings.IndexByte(env[i], '=')
+		env[i] = env[i][:j+1] + values[ref]
+		secrets = append(secrets, values[ref])
+	}
+
+	cmd := exec.Command(flags.Arg(0), flags.Args()[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if !*noMasking {
+		stdout := secretref.NewMaskWriter(os.Stdout, secrets)
+		defer stdout.Close()
+		stderr := secretref.NewMaskWriter(os.Stderr, secrets)
+		defer stderr.Close()
+
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+	}
+
+	if err := cmd.Start(); err != nil {
+		return errors.Wrap(err, "start command")
+	}
+
+	// Forward signals to the command.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	if err := cmd.Wait(); err != nil {
+		if exiterr, ok := err.(*exec.ExitError); ok {
+			if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
+				return exitError(status.ExitStatus())
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// readEnvFile reads KEY=value lines from the file. Blank lines and lines starting with # are
+// skipped, values may be quoted and lines may start with export.
+func readEnvFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var vars []string
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		i := strings.IndexByte(line, '=')
+		if i <= 0 {
+			return nil, errors.Errorf("line %d: expected KEY=value", n)
+		}
+
+		key := strings.TrimSpace(line[:i])
+		value := strings.TrimSpace(line[i+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		vars = append(vars, key+"="+value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return vars, nil
+}
@@ -0,0 +1,96 @@
+package secretref
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Mask replaces secret values in output.
+const Mask = "<concealed by 1pass>"
+
+// MaskWriter replaces the secrets in everything written to it with Mask. Output that could be
+// the start of a secret is held back until it can be decided, call Close to flush it.
+type MaskWriter struct {
+	w       io.Writer
+	secrets [][]byte
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// NewMaskWriter returns a writer masking the secrets before writing to w.
+func NewMaskWriter(w io.Writer, secrets []string) *MaskWriter {
+	mw := MaskWriter{w: w}
+	for _, secret := range secrets {
+		if secret != "" {
+			mw.secrets = append(mw.secrets, []byte(secret))
+		}
+	}
+
+	// Mask the longest secret when secrets overlap.
+	sort.Slice(mw.secrets, func(i, j int) bool {
+		return len(mw.secrets[i]) > len(mw.secrets[j])
+	})
+
+	return &mw
+}
+
+func (mw *MaskWriter) Write(p []byte) (int, error) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	mw.buf = append(mw.buf, p...)
+
+	if err := mw.flush(false); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close writes the output held back.
+func (mw *MaskWriter) Close() error {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	return mw.flush(true)
+}
+
+// flush writes the masked buffer up to the point where the rest could still be the start of
+// a secret. All of it is written if final is set.
+func (mw *MaskWriter) flush(final bool) error {
+	var out bytes.Buffer
+
+	i := 0
+loop:
+	for i < len(mw.buf) {
+		rest := mw.buf[i:]
+
+		// Wait for more output if the rest could still become a longer secret.
+		if !final {
+			for _, secret := range mw.secrets {
+				if len(rest) < len(secret) && bytes.HasPrefix(secret, rest) {
+					break loop
+				}
+			}
+		}
+
+		for _, secret := range mw.secrets {
+			if bytes.HasPrefix(rest, secret) {
+				out.WriteString(Mask)
+				i += len(secret)
+				continue loop
+			}
+		}
+
+		out.WriteByte(mw.buf[i])
+		i++
+	}
+
+	mw.buf = append(mw.buf[:0], mw.buf[i:]...)
+
+	_, err := mw.w.Write(out.Bytes())
+	return err
+}
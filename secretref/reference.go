@@ -0,0 +1,54 @@
+package secretref
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Scheme is the url scheme of secret references.
+const Scheme = "op://"
+
+// Reference is a reference to an item field of the form op://vault/item/field or
+// op://vault/item/section/field. Vaults and items are given by name or uuid.
+type Reference struct {
+	Vault   string
+	Item    string
+	Section string
+	Field   string
+}
+
+// IsReference reports whether s looks like a secret reference.
+func IsReference(s string) bool {
+	return strings.HasPrefix(s, Scheme)
+}
+
+// Parse parses a secret reference.
+func Parse(s string) (Reference, error) {
+	if !IsReference(s) {
+		return Reference{}, errors.Errorf("invalid reference %q: missing %s prefix", s, Scheme)
+	}
+
+	parts := strings.Split(strings.TrimPrefix(s, Scheme), "/")
+	for _, part := range parts {
+		if part == "" {
+			return Reference{}, errors.Errorf("invalid reference %q: empty path segment", s)
+		}
+	}
+
+	switch len(parts) {
+	case 3:
+		return Reference{Vault: parts[0], Item: parts[1], Field: parts[2]}, nil
+	case 4:
+		return Reference{Vault: parts[0], Item: parts[1], Section: parts[2], Field: parts[3]}, nil
+	}
+
+	return Reference{}, errors.Errorf("invalid reference %q: expected op://vault/item/[section/]field", s)
+}
+
+func (r Reference) String() string {
+	if r.Section != "" {
+		return Scheme + r.Vault + "/" + r.Item + "/" + r.Section + "/" + r.Field
+	}
+	return Scheme + r.Vault + "/" + r.Item + "/" + r.Field
+}
@@ -0,0 +1,191 @@
+package secretref
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/michalnicp/1pass/op"
+	"github.com/pkg/errors"
+)
+
+// Resolver resolves secret references to field values.
+type Resolver struct {
+	Session *op.Session
+
+	// Reveal is called with every resolved reference and its item if set.
+	Reveal func(item *op.Item, ref Reference)
+}
+
+// ResolveError lists the references that could not be resolved.
+type ResolveError struct {
+	Errors []error
+}
+
+func (e *ResolveError) Error() string {
+	lines := []string{fmt.Sprintf("%d unresolved references:", len(e.Errors))}
+	for _, err := range e.Errors {
+		lines = append(lines, "  "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Resolve returns the value of the field the reference points to.
+func (r *Resolver) Resolve(ref Reference) (string, error) {
+	item, err := r.item(ref)
+	if err != nil {
+		return "", errors.Wrap(err, ref.String())
+	}
+
+	value, err := field(item, ref)
+	if err != nil {
+		return "", errors.Wrap(err, ref.String())
+	}
+
+	if r.Reveal != nil {
+		r.Reveal(item, ref)
+	}
+
+	return value, nil
+}
+
+// ResolveAll returns the values of the references. Each item is only fetched once no matter
+// how many of its fields are referenced. If any reference can't be resolved a *ResolveError
+// listing all of them is returned.
+func (r *Resolver) ResolveAll(refs []Reference) (map[Reference]string, error) {
+	type itemKey struct {
+		vault, item string
+	}
+
+	var keys []itemKey
+	groups := make(map[itemKey][]Reference)
+	for _, ref := range refs {
+		key := itemKey{strings.ToLower(ref.Vault), strings.ToLower(ref.Item)}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], ref)
+	}
+
+	values := make(map[Reference]string)
+	var errs []error
+	for _, key := range keys {
+		group := groups[key]
+
+		item, err := r.item(group[0])
+		if err != nil {
+			for _, ref := range group {
+				errs = append(errs, errors.Wrap(err, ref.String()))
+			}
+			continue
+		}
+
+		for _, ref := range group {
+			value, err := field(item, ref)
+			if err != nil {
+				errs = append(errs, errors.Wrap(err, ref.String()))
+				continue
+			}
+			values[ref] = value
+
+			if r.Reveal != nil {
+				r.Reveal(item, ref)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, &ResolveError{Errors: errs}
+	}
+
+	return values, nil
+}
+
+// item finds the item the reference points to and returns it with its details.
+func (r *Resolver) item(ref Reference) (*op.Item, error) {
+	vaults, err := r.Session.ListVaults()
+	if err != nil {
+		return nil, errors.Wrap(err, "list vaults")
+	}
+
+	var vaultUUID string
+	for _, vault := range vaults {
+		if vault.UUID == ref.Vault || strings.EqualFold(vault.Name, ref.Vault) {
+			vaultUUID = vault.UUID
+			break
+		}
+	}
+	if vaultUUID == "" {
+		return nil, errors.New("vault not found")
+	}
+
+	items, err := r.Session.ListItems()
+	if err != nil {
+		return nil, errors.Wrap(err, "list items")
+	}
+
+	var matches []op.Item
+	for _, item := range items {
+		if item.VaultUUID != vaultUUID {
+			continue
+		}
+		if item.UUID == ref.Item {
+			matches = []op.Item{item}
+			break
+		}
+		if strings.EqualFold(item.Overview.Title, ref.Item) {
+			matches = append(matches, item)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, errors.New("item not found")
+	case 1:
+	default:
+		return nil, errors.Errorf("%d items are named %q, use the item uuid", len(matches), ref.Item)
+	}
+
+	item, err := r.Session.GetItem(matches[0].UUID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get item")
+	}
+
+	return item, nil
+}
+
+// field returns the value of the referenced field of the item. Fields are matched by
+// designation, name or title. Without a section the item fields are searched before the
+// fields of every section.
+func field(item *op.Item, ref Reference) (string, error) {
+	if item.Details == nil {
+		return "", errors.New("item has no details")
+	}
+
+	if ref.Section == "" {
+		if strings.EqualFold(ref.Field, "notes") && item.Details.Notes != "" {
+			return item.Details.Notes, nil
+		}
+
+		for _, field := range item.Details.Fields {
+			if strings.EqualFold(field.Designation, ref.Field) || strings.EqualFold(field.Name, ref.Field) {
+				return field.Value, nil
+			}
+		}
+	}
+
+	for _, section := range item.Details.Sections {
+		if ref.Section != "" &&
+			!strings.EqualFold(section.Title, ref.Section) &&
+			!strings.EqualFold(section.Name, ref.Section) {
+			continue
+		}
+
+		for _, field := range section.Fields {
+			if strings.EqualFold(field.Title, ref.Field) || strings.EqualFold(field.Name, ref.Field) {
+				return field.Value, nil
+			}
+		}
+	}
+
+	return "", errors.New("field not found")
+}
@@ -0,0 +1,52 @@
+package secretref
+
+import (
+	"regexp"
+	"strings"
+)
+
+// templateRe matches the references in a template, eg. {{ op://vault/item/field }}. Vault,
+// item and field names may contain spaces.
+var templateRe = regexp.MustCompile(`\{\{\s*(op://[^{}]*?)\s*\}\}`)
+
+// Render returns the template with every reference replaced by its value. If any reference
+// is invalid or can't be resolved a *ResolveError listing all of them is returned.
+func (r *Resolver) Render(template []byte) ([]byte, error) {
+	matches := templateRe.FindAllSubmatchIndex(template, -1)
+
+	refs := make([]Reference, 0, len(matches))
+	var errs []error
+	for _, match := range matches {
+		ref, err := Parse(strings.TrimSpace(string(template[match[2]:match[3]])))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		refs = append(refs, ref)
+	}
+
+	values, err := r.ResolveAll(refs)
+	if err != nil {
+		rerr, ok := err.(*ResolveError)
+		if !ok {
+			return nil, err
+		}
+		errs = append(errs, rerr.Errors...)
+	}
+
+	if len(errs) > 0 {
+		return nil, &ResolveError{Errors: errs}
+	}
+
+	// Every reference was parsed, so refs lines up with matches.
+	var out []byte
+	last := 0
+	for i, match := range matches {
+		out = append(out, template[last:match[0]]...)
+		out = append(out, values[refs[i]]...)
+		last = match[1]
+	}
+	out = append(out, template[last:]...)
+
+	return out, nil
+}
@@ -0,0 +1,57 @@
+package secretref
+
+import (
+	"testing"
+
+	"github.com/michalnicp/1pass/op"
+	"github.com/michalnicp/1pass/op/optest"
+)
+
+func TestMain(m *testing.M) {
+	optest.Main(m)
+}
+
+func TestRender(t *testing.T) {
+	login := op.Item{TemplateUUID: op.TemplateLogin, Details: &op.Details{
+		Fields: []op.DetailsField{
+			{Designation: "username", Name: "username", Value: "admin"},
+			{Designation: "password", Name: "password", Value: "s3cret"},
+		},
+		Sections: []op.Section{{Title: "Server Info", Fields: []op.SectionField{
+			{Title: "host name", Value: "db.example.com"},
+		}}},
+	}}
+	login.Overview.Title = "My Login"
+
+	fake, err := optest.New([]op.Vault{{UUID: "vault", Name: "Private"}}, []op.Item{login}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	session, err := fake.Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Resolver{Session: session}
+
+	template := "user={{ op://Private/My Login/username }}\n" +
+		"password={{op://Private/My Login/password}}\n" +
+		"host={{   op://Private/My Login/Server Info/host name   }}\n" +
+		"literal={{ not a reference }}\n"
+	want := "user=admin\npassword=s3cret\nhost=db.example.com\nliteral={{ not a reference }}\n"
+
+	out, err := r.Render([]byte(template))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != want {
+		t.Fatalf("got %q, want %q", out, want)
+	}
+
+	_, err = r.Render([]byte("{{ op://Private/My Login/missing }} {{ op://Private/My Login }}"))
+	rerr, ok := err.(*ResolveError)
+	if !ok || len(rerr.Errors) != 2 {
+		t.Fatalf("got error %v, want 2 unresolved references", err)
+	}
+}
@@ -0,0 +1,848 @@
+// Package secretservice implements the freedesktop Secret Service API, which applications
+// such as NetworkManager, browsers and libsecret use to store passwords, with 1Password items.
+//
+// Collections are vaults and items are the items of those vaults. The attributes of an item
+// are its fields that aren't concealed, and its secret is its password. Items created by
+// clients are password items with their attributes in a section of the item.
+package secretservice
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus"
+	"github.com/michalnicp/1pass/op"
+	"github.com/michalnicp/1pass/securemem"
+	errors2 "github.com/pkg/errors"
+)
+
+const (
+	busName = "org.freedesktop.secrets"
+
+	servicePath    = "/org/freedesktop/secrets"
+	collectionPath = servicePath + "/collection/"
+	aliasPath      = servicePath + "/aliases/"
+	sessionPath    = servicePath + "/session/"
+	promptPath     = servicePath + "/prompt/"
+
+	serviceInterface    = "org.freedesktop.Secret.Service"
+	collectionInterface = "org.freedesktop.Secret.Collection"
+	itemInterface       = "org.freedesktop.Secret.Item"
+	sessionInterface    = "org.freedesktop.Secret.Session"
+	promptInterface     = "org.freedesktop.Secret.Prompt"
+	propertiesInterface = "org.freedesktop.DBus.Properties"
+
+	labelProperty      = itemInterface + ".Label"
+	attributesProperty = itemInterface + ".Attributes"
+
+	// attributesSection is the section of the items created by clients holding the
+	// attributes.
+	attributesSection = "Secret Service"
+
+	// noPrompt is the path returned when no prompt is needed.
+	noPrompt = dbus.ObjectPath("/")
+)
+
+var (
+
+	// ErrNameTaken is returned by Start when another secret service, eg. gnome-keyring, is
+	// running.
+	ErrNameTaken = errors.New("another secret service is running")
+
+	errNoSession    = dbus.NewError("org.freedesktop.Secret.Error.NoSession", []interface{}{"no such session"})
+	errNoSuchObject = dbus.NewError("org.freedesktop.Secret.Error.NoSuchObject", []interface{}{"no such object"})
+	errIsLocked     = dbus.NewError("org.freedesktop.Secret.Error.IsLocked", []interface{}{"1pass is locked"})
+	errNotSupported = dbus.NewError("org.freedesktop.DBus.Error.NotSupported", []interface{}{"not supported by 1pass"})
+)
+
+// Secret is a secret sent over the bus, (oayays).
+type Secret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// Service serves the Secret Service API on the session bus.
+type Service struct {
+
+	// Session returns the current session, or nil if not signed in.
+	Session func() *op.Session
+
+	// Vaults are the vaults served as collections, given by name or uuid. The first vault is
+	// the default collection new items are created in.
+	Vaults []string
+
+	// Unlock asks the user to unlock 1pass. It blocks until the session is unlocked or the
+	// user gives up and reports whether it was unlocked.
+	Unlock func() bool
+
+	// Reveal is called with every item whose secret is sent to a client.
+	Reveal func(item *op.Item)
+
+	conn *dbus.Conn
+
+	mu       sync.Mutex
+	sessions map[dbus.ObjectPath]*session
+	prompts  map[dbus.ObjectPath][]dbus.ObjectPath // objects to unlock by prompt
+	nextID   int
+}
+
+// Start connects to the session bus and serves the API until the connection is closed.
+func (s *Service) Start() error {
+	conn, err := dbus.SessionBusPrivate()
+	if err != nil {
+		return errors2.Wrap(err, "connect to session bus")
+	}
+	if err := conn.Auth(nil); err != nil {
+		conn.Close()
+		return errors2.Wrap(err, "authenticate to session bus")
+	}
+	if err := conn.Hello(); err != nil {
+		conn.Close()
+		return errors2.Wrap(err, "hello session bus")
+	}
+
+	s.conn = conn
+	s.sessions = make(map[dbus.ObjectPath]*session)
+	s.prompts = make(map[dbus.ObjectPath][]dbus.ObjectPath)
+
+	// Every object is under the service path, the methods look at the path of the message.
+	exports := map[string]interface{}{
+		serviceInterface:    serviceObject{s},
+		collectionInterface: collectionObject{s},
+		itemInterface:       itemObject{s},
+		sessionInterface:    sessionObject{s},
+		promptInterface:     promptObject{s},
+		propertiesInterface: propertiesObject{s},
+	}
+	for iface, v := range exports {
+		if err := conn.ExportSubtree(v, servicePath, iface); err != nil {
+			conn.Close()
+			return errors2.Wrapf(err, "export %s", iface)
+		}
+	}
+
+	// Close the sessions of clients that disconnect.
+	rule := "type='signal',interface='org.freedesktop.DBus',member='NameOwnerChanged'"
+	if call := conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, rule); call.Err != nil {
+		conn.Close()
+		return errors2.Wrap(call.Err, "watch clients")
+	}
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+	go s.closeSessions(signals)
+
+	reply, err := conn.RequestName(busName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		conn.Close()
+		return errors2.Wrap(err, "request name")
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		conn.Close()
+		return errors2.WithStack(ErrNameTaken)
+	}
+
+	return nil
+}
+
+// closeSessions closes the sessions of the clients that disconnect.
+func (s *Service) closeSessions(signals chan *dbus.Signal) {
+	for signal := range signals {
+		if signal.Name != "org.freedesktop.DBus.NameOwnerChanged" || len(signal.Body) != 3 {
+			continue
+		}
+		name, _ := signal.Body[0].(string)
+		if owner, _ := signal.Body[2].(string); owner != "" {
+			continue
+		}
+
+		s.mu.Lock()
+		for p, session := range s.sessions {
+			if session.owner == name {
+				delete(s.sessions, p)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Close stops serving the API.
+func (s *Service) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// path returns the object path of a message.
+func path(msg dbus.Message) dbus.ObjectPath {
+	p, _ := msg.Headers[dbus.FieldPath].Value().(dbus.ObjectPath)
+	return p
+}
+
+// newPath returns a new path with the prefix for a session or prompt.
+func (s *Service) newPath(prefix string) dbus.ObjectPath {
+	s.nextID++
+	return dbus.ObjectPath(prefix + strconv.Itoa(s.nextID))
+}
+
+// session returns the session if it can be used, or an error for the client.
+func (s *Service) session() (*op.Session, *dbus.Error) {
+	session := s.Session()
+	if !session.Valid() {
+		return nil, errIsLocked
+	}
+	return session, nil
+}
+
+// vaults returns the served vaults, the default vault first.
+func (s *Service) vaults(session *op.Session) ([]op.Vault, *dbus.Error) {
+	all, err := session.ListVaults()
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+
+	var vaults []op.Vault
+	for _, name := range s.Vaults {
+		for _, vault := range all {
+			if vault.UUID == name || strings.EqualFold(vault.Name, name) {
+				vaults = append(vaults, vault)
+			}
+		}
+	}
+	return vaults, nil
+}
+
+// collection returns the vault of a collection or alias path.
+func (s *Service) collection(session *op.Session, p dbus.ObjectPath) (op.Vault, *dbus.Error) {
+	vaults, err := s.vaults(session)
+	if err != nil {
+		return op.Vault{}, err
+	}
+
+	if strings.HasPrefix(string(p), aliasPath) {
+		if strings.TrimPrefix(string(p), aliasPath) == "default" && len(vaults) > 0 {
+			return vaults[0], nil
+		}
+		return op.Vault{}, errNoSuchObject
+	}
+
+	for _, vault := range vaults {
+		if p == collectionObjectPath(vault) {
+			return vault, nil
+		}
+	}
+	return op.Vault{}, errNoSuchObject
+}
+
+// item returns the item of an item path with its details.
+func (s *Service) item(session *op.Session, p dbus.ObjectPath) (*op.Item, *dbus.Error) {
+	i := strings.LastIndex(string(p), "/")
+	if i < 0 {
+		return nil, errNoSuchObject
+	}
+	vault, err := s.collection(session, p[:i])
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.items(session, vault)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if itemObjectPath(item) == p {
+			return item, nil
+		}
+	}
+	return nil, errNoSuchObject
+}
+
+// items returns the items of the vault with their details.
+func (s *Service) items(session *op.Session, vault op.Vault) ([]*op.Item, *dbus.Error) {
+	overviews, err := session.ListItems()
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+
+	var items []*op.Item
+	for _, overview := range overviews {
+		if overview.VaultUUID != vault.UUID {
+			continue
+		}
+
+		details, err := session.GetItem(overview.UUID)
+		if err != nil {
+			return nil, dbus.MakeFailedError(err)
+		}
+
+		item := overview
+		item.Details = details.Details
+		items = append(items, &item)
+	}
+	return items, nil
+}
+
+// search returns the paths of the items in the vaults with the attributes.
+func (s *Service) search(session *op.Session, vaults []op.Vault, attributes map[string]string) ([]dbus.ObjectPath, *dbus.Error) {
+	found := []dbus.ObjectPath{}
+	for _, vault := range vaults {
+		items, err := s.items(session, vault)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			if matches(item, attributes) {
+				found = append(found, itemObjectPath(item))
+			}
+		}
+	}
+	return found, nil
+}
+
+// secret returns the secret of the item encrypted for the session.
+func (s *Service) secret(item *op.Item, sessionPath dbus.ObjectPath) (Secret, *dbus.Error) {
+	s.mu.Lock()
+	session, ok := s.sessions[sessionPath]
+	s.mu.Unlock()
+	if !ok {
+		return Secret{}, errNoSession
+	}
+
+	value := []byte(password(item))
+	defer securemem.Wipe(value)
+
+	parameters, encrypted, err := session.encrypt(value)
+	if err != nil {
+		return Secret{}, dbus.MakeFailedError(err)
+	}
+
+	if s.Reveal != nil {
+		s.Reveal(item)
+	}
+
+	return Secret{
+		Session:     sessionPath,
+		Parameters:  parameters,
+		Value:       encrypted,
+		ContentType: "text/plain",
+	}, nil
+}
+
+func collectionObjectPath(vault op.Vault) dbus.ObjectPath {
+	return dbus.ObjectPath(collectionPath + vault.UUID)
+}
+
+func itemObjectPath(item *op.Item) dbus.ObjectPath {
+	return dbus.ObjectPath(collectionPath + item.VaultUUID + "/" + item.UUID)
+}
+
+// attributes returns the fields of the item that aren't concealed.
+func attributes(item *op.Item) map[string]string {
+	attrs := make(map[string]string)
+	if item.Details == nil {
+		return attrs
+	}
+
+	for _, field := range item.Details.Fields {
+		name := field.Designation
+		if name == "" {
+			name = field.Name
+		}
+		if field.Type != "P" && name != "" {
+			attrs[name] = field.Value
+		}
+	}
+	for _, section := range item.Details.Sections {
+		for _, field := range section.Fields {
+			if field.Type != "concealed" && field.Title != "" {
+				attrs[field.Title] = field.Value
+			}
+		}
+	}
+	return attrs
+}
+
+// matches reports whether the item has all the attributes.
+func matches(item *op.Item, attrs map[string]string) bool {
+	have := attributes(item)
+	for k, v := range attrs {
+		if value, ok := have[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// password returns the secret of the item.
+func password(item *op.Item) string {
+	if item.Details == nil {
+		return ""
+	}
+	if item.Details.Password != "" {
+		return item.Details.Password
+	}
+	return item.Details.Value("password")
+}
+
+// serviceObject implements org.freedesktop.Secret.Service.
+type serviceObject struct {
+	s *Service
+}
+
+func (o serviceObject) OpenSession(msg dbus.Message, algorithm string, input dbus.Variant) (dbus.Variant, dbus.ObjectPath, *dbus.Error) {
+	if path(msg) != servicePath {
+		return dbus.Variant{}, "", errNoSuchObject
+	}
+	sender, _ := msg.Headers[dbus.FieldSender].Value().(string)
+
+	var sess *session
+	output := dbus.MakeVariant("")
+	switch algorithm {
+	case algorithmPlain:
+		sess = &session{owner: sender}
+	case algorithmDH:
+		public, ok := input.Value().([]byte)
+		if !ok {
+			return dbus.Variant{}, "", dbus.NewError("org.freedesktop.DBus.Error.InvalidArgs", []interface{}{"input must be a public key"})
+		}
+		var err error
+		var serverPublic []byte
+		sess, serverPublic, err = newDHSession(sender, public)
+		if err != nil {
+			return dbus.Variant{}, "", dbus.NewError("org.freedesktop.DBus.Error.InvalidArgs", []interface{}{err.Error()})
+		}
+		output = dbus.MakeVariant(serverPublic)
+	default:
+		return dbus.Variant{}, "", errNotSupported
+	}
+
+	o.s.mu.Lock()
+	p := o.s.newPath(sessionPath)
+	o.s.sessions[p] = sess
+	o.s.mu.Unlock()
+
+	return output, p, nil
+}
+
+// CreateCollection returns the collection of an existing vault with the label, vaults can't
+// be created.
+func (o serviceObject) CreateCollection(msg dbus.Message, properties map[string]dbus.Variant, alias string) (dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	session, err := o.s.session()
+	if err != nil {
+		return "", "", err
+	}
+
+	if alias == "default" {
+		vault, err := o.s.collection(session, aliasPath+"default")
+		if err != nil {
+			return "", "", err
+		}
+		return collectionObjectPath(vault), noPrompt, nil
+	}
+
+	label, _ := properties["org.freedesktop.Secret.Collection.Label"].Value().(string)
+	vaults, err := o.s.vaults(session)
+	if err != nil {
+		return "", "", err
+	}
+	for _, vault := range vaults {
+		if strings.EqualFold(vault.Name, label) {
+			return collectionObjectPath(vault), noPrompt, nil
+		}
+	}
+
+	return "", "", errNotSupported
+}
+
+// SearchItems returns the items with the attributes. Nothing is found while locked, the items
+// can't be listed.
+func (o serviceObject) SearchItems(msg dbus.Message, attributes map[string]string) ([]dbus.ObjectPath, []dbus.ObjectPath, *dbus.Error) {
+	session, err := o.s.session()
+	if err != nil {
+		return []dbus.ObjectPath{}, []dbus.ObjectPath{}, nil
+	}
+
+	vaults, err := o.s.vaults(session)
+	if err != nil {
+		return nil, nil, err
+	}
+	unlocked, err := o.s.search(session, vaults, attributes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return unlocked, []dbus.ObjectPath{}, nil
+}
+
+// Unlock unlocks 1pass. Everything is unlocked with the session, so the objects are either
+// all unlocked or a prompt asks the user to unlock 1pass.
+func (o serviceObject) Unlock(msg dbus.Message, objects []dbus.ObjectPath) ([]dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	if o.s.Session().Valid() {
+		return objects, noPrompt, nil
+	}
+
+	o.s.mu.Lock()
+	p := o.s.newPath(promptPath)
+	o.s.prompts[p] = objects
+	o.s.mu.Unlock()
+
+	return []dbus.ObjectPath{}, p, nil
+}
+
+// Lock doesn't lock anything, 1pass is locked from its window.
+func (o serviceObject) Lock(msg dbus.Message, objects []dbus.ObjectPath) ([]dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	return []dbus.ObjectPath{}, noPrompt, nil
+}
+
+func (o serviceObject) GetSecrets(msg dbus.Message, items []dbus.ObjectPath, sessionPath dbus.ObjectPath) (map[dbus.ObjectPath]Secret, *dbus.Error) {
+	session, err := o.s.session()
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make(map[dbus.ObjectPath]Secret)
+	for _, p := range items {
+		item, err := o.s.item(session, p)
+		if err != nil {
+			continue
+		}
+		secret, err := o.s.secret(item, sessionPath)
+		if err != nil {
+			return nil, err
+		}
+		secrets[p] = secret
+	}
+	return secrets, nil
+}
+
+func (o serviceObject) ReadAlias(msg dbus.Message, name string) (dbus.ObjectPath, *dbus.Error) {
+	session, err := o.s.session()
+	if err != nil {
+		return noPrompt, nil
+	}
+	vault, err := o.s.collection(session, dbus.ObjectPath(aliasPath+name))
+	if err != nil {
+		return noPrompt, nil
+	}
+	return collectionObjectPath(vault), nil
+}
+
+// SetAlias isn't supported, the default collection is set in the config.
+func (o serviceObject) SetAlias(msg dbus.Message, name string, collection dbus.ObjectPath) *dbus.Error {
+	return errNotSupported
+}
+
+// collectionObject implements org.freedesktop.Secret.Collection.
+type collectionObject struct {
+	s *Service
+}
+
+// Delete isn't supported, vaults are deleted in 1Password.
+func (o collectionObject) Delete(msg dbus.Message) (dbus.ObjectPath, *dbus.Error) {
+	return "", errNotSupported
+}
+
+func (o collectionObject) SearchItems(msg dbus.Message, attributes map[string]string) ([]dbus.ObjectPath, *dbus.Error) {
+	session, err := o.s.session()
+	if err != nil {
+		return nil, err
+	}
+	vault, err := o.s.collection(session, path(msg))
+	if err != nil {
+		return nil, err
+	}
+	return o.s.search(session, []op.Vault{vault}, attributes)
+}
+
+// CreateItem creates a password item. Items can't be edited, replace deletes the items with
+// the same attributes instead.
+func (o collectionObject) CreateItem(msg dbus.Message, properties map[string]dbus.Variant, secret Secret, replace bool) (dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	session, err := o.s.session()
+	if err != nil {
+		return "", "", err
+	}
+	vault, err := o.s.collection(session, path(msg))
+	if err != nil {
+		return "", "", err
+	}
+
+	o.s.mu.Lock()
+	sess, ok := o.s.sessions[secret.Session]
+	o.s.mu.Unlock()
+	if !ok {
+		return "", "", errNoSession
+	}
+
+	value, decryptErr := sess.decrypt(secret.Parameters, secret.Value)
+	if decryptErr != nil {
+		return "", "", dbus.NewError("org.freedesktop.DBus.Error.InvalidArgs", []interface{}{decryptErr.Error()})
+	}
+	defer securemem.Wipe(value)
+
+	label, attrs, propErr := itemProperties(properties)
+	if propErr != nil {
+		return "", "", propErr
+	}
+
+	// Without attributes every item of the vault would match.
+	if replace && len(attrs) > 0 {
+		existing, err := o.s.search(session, []op.Vault{vault}, attrs)
+		if err != nil {
+			return "", "", err
+		}
+		for _, p := range existing {
+			id := string(p[strings.LastIndex(string(p), "/")+1:])
+			if err := session.DeleteItem(id); err != nil {
+				return "", "", dbus.MakeFailedError(err)
+			}
+		}
+	}
+
+	section := op.Section{Name: "secret_service", Title: attributesSection}
+	for k, v := range attrs {
+		section.Fields = append(section.Fields, op.SectionField{Type: "string", Name: k, Title: k, Value: v})
+	}
+	item := op.Item{
+		Details: &op.Details{
+			Password: string(value),
+			Sections: []op.Section{section},
+		},
+	}
+	item.Overview.Title = label
+
+	created, createErr := session.CreateItem(op.Categories[op.TemplatePassword], vault.UUID, &item)
+	if createErr != nil {
+		return "", "", dbus.MakeFailedError(createErr)
+	}
+	created.VaultUUID = vault.UUID
+
+	return itemObjectPath(created), noPrompt, nil
+}
+
+// itemProperties returns the label and attributes of a new item, which are both optional.
+func itemProperties(properties map[string]dbus.Variant) (string, map[string]string, *dbus.Error) {
+	var label string
+	if v, ok := properties[labelProperty]; ok {
+		if label, ok = v.Value().(string); !ok {
+			return "", nil, dbus.NewError("org.freedesktop.DBus.Error.InvalidArgs", []interface{}{"label must be a string"})
+		}
+	}
+
+	var attrs map[string]string
+	if v, ok := properties[attributesProperty]; ok {
+		if attrs, ok = v.Value().(map[string]string); !ok {
+			return "", nil, dbus.NewError("org.freedesktop.DBus.Error.InvalidArgs", []interface{}{"attributes must be a dict of strings"})
+		}
+	}
+
+	return label, attrs, nil
+}
+
+// itemObject implements org.freedesktop.Secret.Item.
+type itemObject struct {
+	s *Service
+}
+
+func (o itemObject) Delete(msg dbus.Message) (dbus.ObjectPath, *dbus.Error) {
+	session, err := o.s.session()
+	if err != nil {
+		return "", err
+	}
+	item, err := o.s.item(session, path(msg))
+	if err != nil {
+		return "", err
+	}
+	if err := session.DeleteItem(item.UUID); err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return noPrompt, nil
+}
+
+func (o itemObject) GetSecret(msg dbus.Message, sessionPath dbus.ObjectPath) (Secret, *dbus.Error) {
+	session, err := o.s.session()
+	if err != nil {
+		return Secret{}, err
+	}
+	item, err := o.s.item(session, path(msg))
+	if err != nil {
+		return Secret{}, err
+	}
+	return o.s.secret(item, sessionPath)
+}
+
+// SetSecret isn't supported, op can't edit items.
+func (o itemObject) SetSecret(msg dbus.Message, secret Secret) *dbus.Error {
+	return errNotSupported
+}
+
+// sessionObject implements org.freedesktop.Secret.Session.
+type sessionObject struct {
+	s *Service
+}
+
+func (o sessionObject) Close(msg dbus.Message) *dbus.Error {
+	o.s.mu.Lock()
+	defer o.s.mu.Unlock()
+
+	if _, ok := o.s.sessions[path(msg)]; !ok {
+		return errNoSuchObject
+	}
+	delete(o.s.sessions, path(msg))
+	return nil
+}
+
+// promptObject implements org.freedesktop.Secret.Prompt.
+type promptObject struct {
+	s *Service
+}
+
+// Prompt asks the user to unlock 1pass and signals Completed with the unlocked objects.
+func (o promptObject) Prompt(msg dbus.Message, windowID string) *dbus.Error {
+	p := path(msg)
+
+	o.s.mu.Lock()
+	objects, ok := o.s.prompts[p]
+	o.s.mu.Unlock()
+	if !ok {
+		return errNoSuchObject
+	}
+
+	go func() {
+		unlocked := o.s.Unlock != nil && o.s.Unlock()
+		o.complete(p, !unlocked, objects)
+	}()
+
+	return nil
+}
+
+func (o promptObject) Dismiss(msg dbus.Message) *dbus.Error {
+	p := path(msg)
+
+	o.s.mu.Lock()
+	_, ok := o.s.prompts[p]
+	o.s.mu.Unlock()
+	if !ok {
+		return errNoSuchObject
+	}
+
+	o.complete(p, true, nil)
+	return nil
+}
+
+// complete removes the prompt and signals the result.
+func (o promptObject) complete(p dbus.ObjectPath, dismissed bool, objects []dbus.ObjectPath) {
+	o.s.mu.Lock()
+	_, ok := o.s.prompts[p]
+	delete(o.s.prompts, p)
+	o.s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	if dismissed || objects == nil {
+		objects = []dbus.ObjectPath{}
+	}
+	o.s.conn.Emit(p, promptInterface+".Completed", dismissed, dbus.MakeVariant(objects))
+}
+
+// propertiesObject implements org.freedesktop.DBus.Properties for every object. Properties
+// are read only.
+type propertiesObject struct {
+	s *Service
+}
+
+func (o propertiesObject) Get(msg dbus.Message, iface, property string) (dbus.Variant, *dbus.Error) {
+	props, err := o.s.properties(path(msg), iface)
+	if err != nil {
+		return dbus.Variant{}, err
+	}
+	value, ok := props[property]
+	if !ok {
+		return dbus.Variant{}, dbus.NewError("org.freedesktop.DBus.Error.UnknownProperty", []interface{}{fmt.Sprintf("unknown property %s", property)})
+	}
+	return value, nil
+}
+
+func (o propertiesObject) GetAll(msg dbus.Message, iface string) (map[string]dbus.Variant, *dbus.Error) {
+	return o.s.properties(path(msg), iface)
+}
+
+func (o propertiesObject) Set(msg dbus.Message, iface, property string, value dbus.Variant) *dbus.Error {
+	return errNotSupported
+}
+
+// properties returns the properties of the object.
+func (s *Service) properties(p dbus.ObjectPath, iface string) (map[string]dbus.Variant, *dbus.Error) {
+	locked := !s.Session().Valid()
+
+	switch iface {
+	case serviceInterface:
+		if p != servicePath {
+			return nil, errNoSuchObject
+		}
+		collections := []dbus.ObjectPath{}
+		if session, err := s.session(); err == nil {
+			vaults, err := s.vaults(session)
+			if err != nil {
+				return nil, err
+			}
+			for _, vault := range vaults {
+				collections = append(collections, collectionObjectPath(vault))
+			}
+		}
+		return map[string]dbus.Variant{
+			"Collections": dbus.MakeVariant(collections),
+		}, nil
+
+	case collectionInterface:
+		session, err := s.session()
+		if err != nil {
+			return map[string]dbus.Variant{
+				"Items":    dbus.MakeVariant([]dbus.ObjectPath{}),
+				"Label":    dbus.MakeVariant(""),
+				"Locked":   dbus.MakeVariant(true),
+				"Created":  dbus.MakeVariant(uint64(0)),
+				"Modified": dbus.MakeVariant(uint64(0)),
+			}, nil
+		}
+		vault, err := s.collection(session, p)
+		if err != nil {
+			return nil, err
+		}
+		items, err := s.search(session, []op.Vault{vault}, nil)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]dbus.Variant{
+			"Items":    dbus.MakeVariant(items),
+			"Label":    dbus.MakeVariant(vault.Name),
+			"Locked":   dbus.MakeVariant(locked),
+			"Created":  dbus.MakeVariant(uint64(0)),
+			"Modified": dbus.MakeVariant(uint64(0)),
+		}, nil
+
+	case itemInterface:
+		session, err := s.session()
+		if err != nil {
+			return nil, err
+		}
+		item, err := s.item(session, p)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]dbus.Variant{
+			"Locked":     dbus.MakeVariant(locked),
+			"Attributes": dbus.MakeVariant(attributes(item)),
+			"Label":      dbus.MakeVariant(item.Overview.Title),
+			"Created":    dbus.MakeVariant(uint64(item.CreatedAt.Unix())),
+			"Modified":   dbus.MakeVariant(uint64(item.UpdatedAt.Unix())),
+		}, nil
+	}
+
+	return nil, dbus.NewError("org.freedesktop.DBus.Error.UnknownInterface", []interface{}{fmt.Sprintf("unknown interface %s", iface)})
+}
@@ -0,0 +1,265 @@
+package secretservice
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus"
+	"github.com/michalnicp/1pass/dbustest"
+	"github.com/michalnicp/1pass/op"
+	"github.com/michalnicp/1pass/op/optest"
+	"github.com/pkg/errors"
+)
+
+func TestMain(m *testing.M) {
+	optest.Main(m)
+}
+
+// client calls the service over the private session bus.
+type client struct {
+	t       *testing.T
+	conn    *dbus.Conn
+	session dbus.ObjectPath
+	dh      *session
+}
+
+// newTestService serves the items with the fake op on a private session bus and returns a
+// client with an encrypted session. The returned function stops the service and the bus.
+func newTestService(t *testing.T, items []op.Item) (*client, *optest.Fake, func()) {
+	bus, err := dbustest.Start()
+	if errors.Cause(err) == dbustest.ErrNotInstalled {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := bus.Conn()
+	if err != nil {
+		bus.Close()
+		t.Fatal(err)
+	}
+
+	fake, err := optest.New(nil, items, nil)
+	if err != nil {
+		conn.Close()
+		bus.Close()
+		t.Fatal(err)
+	}
+	closeAll := func() {
+		fake.Close()
+		conn.Close()
+		bus.Close()
+	}
+
+	signedIn, err := op.Signin(optest.SigninAddress, optest.Email, []byte(optest.SecretKey), []byte(optest.MasterPassword))
+	if err != nil {
+		closeAll()
+		t.Fatal(err)
+	}
+
+	s := &Service{
+		Session: func() *op.Session { return signedIn },
+		Vaults:  []string{"Private"},
+	}
+	if err := s.Start(); err != nil {
+		closeAll()
+		t.Fatal(err)
+	}
+
+	c := &client{t: t, conn: conn}
+
+	dh := newClientDH(t)
+	var output dbus.Variant
+	if err := c.service().Call(serviceInterface+".OpenSession", 0, algorithmDH, dbus.MakeVariant(dh.public)).Store(&output, &c.session); err != nil {
+		t.Fatal(err)
+	}
+	c.dh = dh.session(t, output.Value().([]byte))
+
+	return c, fake, func() {
+		s.Close()
+		closeAll()
+	}
+}
+
+func (c *client) service() dbus.BusObject {
+	return c.conn.Object(busName, servicePath)
+}
+
+// search returns the items with the attributes.
+func (c *client) search(attrs map[string]string) []dbus.ObjectPath {
+	var unlocked, locked []dbus.ObjectPath
+	if err := c.service().Call(serviceInterface+".SearchItems", 0, attrs).Store(&unlocked, &locked); err != nil {
+		c.t.Fatal(err)
+	}
+	return unlocked
+}
+
+// secret returns the secret of the item.
+func (c *client) secret(item dbus.ObjectPath) string {
+	var secret Secret
+	if err := c.conn.Object(busName, item).Call(itemInterface+".GetSecret", 0, c.session).Store(&secret); err != nil {
+		c.t.Fatal(err)
+	}
+	value, err := c.dh.decrypt(secret.Parameters, secret.Value)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return string(value)
+}
+
+// create creates an item in the default collection.
+func (c *client) create(properties map[string]dbus.Variant, value string, replace bool) error {
+	parameters, encrypted, err := c.dh.encrypt([]byte(value))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	secret := Secret{Session: c.session, Parameters: parameters, Value: encrypted, ContentType: "text/plain"}
+
+	var item, prompt dbus.ObjectPath
+	return c.conn.Object(busName, aliasPath+"default").Call(collectionInterface+".CreateItem", 0, properties, secret, replace).Store(&item, &prompt)
+}
+
+func TestSearch(t *testing.T) {
+	login := op.Item{TemplateUUID: op.TemplateLogin, Details: &op.Details{Fields: []op.DetailsField{
+		{Designation: "username", Value: "alice"},
+		{Designation: "password", Type: "P", Value: "alice-password"},
+	}}}
+	other := op.Item{TemplateUUID: op.TemplateLogin, Details: &op.Details{Fields: []op.DetailsField{
+		{Designation: "username", Value: "bob"},
+		{Designation: "password", Type: "P", Value: "bob-password"},
+	}}}
+
+	c, _, done := newTestService(t, []op.Item{login, other})
+	defer done()
+
+	found := c.search(map[string]string{"username": "alice"})
+	if len(found) != 1 {
+		t.Fatalf("found %v, want 1 item", found)
+	}
+	if got := c.secret(found[0]); got != "alice-password" {
+		t.Fatalf("got secret %q", got)
+	}
+
+	// Concealed fields aren't attributes.
+	if found := c.search(map[string]string{"password": "alice-password"}); len(found) != 0 {
+		t.Fatalf("found %v by password", found)
+	}
+	if found := c.search(map[string]string{"username": "carol"}); len(found) != 0 {
+		t.Fatalf("found %v", found)
+	}
+	if found := c.search(map[string]string{}); len(found) != 2 {
+		t.Fatalf("found %v, want all items", found)
+	}
+}
+
+func TestCreateItem(t *testing.T) {
+	c, fake, done := newTestService(t, nil)
+	defer done()
+
+	attrs := map[string]string{"service": "example", "username": "alice"}
+	properties := map[string]dbus.Variant{
+		labelProperty:      dbus.MakeVariant("example"),
+		attributesProperty: dbus.MakeVariant(attrs),
+	}
+
+	if err := c.create(properties, "first", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.create(properties, "second", true); err != nil {
+		t.Fatal(err)
+	}
+
+	found := c.search(attrs)
+	if len(found) != 1 {
+		t.Fatalf("found %v after replacing, want 1 item", found)
+	}
+	if got := c.secret(found[0]); got != "second" {
+		t.Fatalf("got secret %q, want second", got)
+	}
+
+	// Replacing without attributes would match every item, nothing is deleted.
+	if err := c.create(map[string]dbus.Variant{labelProperty: dbus.MakeVariant("no attributes")}, "third", true); err != nil {
+		t.Fatal(err)
+	}
+	if items, _ := fake.Items(); len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+
+	// Malformed properties are rejected.
+	for _, properties := range []map[string]dbus.Variant{
+		{attributesProperty: dbus.MakeVariant("service=example")},
+		{attributesProperty: dbus.MakeVariant(map[string]int32{"service": 1})},
+		{labelProperty: dbus.MakeVariant(int32(1))},
+	} {
+		err := c.create(properties, "fourth", true)
+		if e, ok := err.(dbus.Error); !ok || e.Name != "org.freedesktop.DBus.Error.InvalidArgs" {
+			t.Errorf("create with %v: got %v, want InvalidArgs", properties, err)
+		}
+	}
+	if items, _ := fake.Items(); len(items) != 2 {
+		t.Fatalf("got %d items after malformed creates, want 2", len(items))
+	}
+}
+
+// TestSecretTool stores, looks up, searches and clears a password with secret-tool of
+// libsecret, as applications using the secret service do.
+func TestSecretTool(t *testing.T) {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		t.Skip("secret-tool is not installed")
+	}
+
+	_, fake, done := newTestService(t, nil)
+	defer done()
+
+	secretTool := func(stdin string, args ...string) (string, error) {
+		cmd := exec.Command("secret-tool", args...)
+		cmd.Stdin = strings.NewReader(stdin)
+		out, err := cmd.Output()
+		if e, ok := err.(*exec.ExitError); ok {
+			err = errors.Errorf("secret-tool %s: %v: %s", args[0], err, e.Stderr)
+		}
+		return string(out), err
+	}
+
+	attrs := []string{"service", "example", "username", "alice"}
+
+	// Storing again replaces the password.
+	for _, password := range []string{"first", "second"} {
+		if _, err := secretTool(password, append([]string{"store", "--label=example"}, attrs...)...); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := secretTool("", append([]string{"lookup"}, attrs...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != password {
+			t.Fatalf("lookup got %q, want %q", got, password)
+		}
+	}
+	if items, _ := fake.Items(); len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+
+	out, err := secretTool("", "search", "service", "example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"label = example", "secret = second", "attribute.username = alice"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("search output %q doesn't contain %q", out, want)
+		}
+	}
+
+	if _, err := secretTool("", append([]string{"clear"}, attrs...)...); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := secretTool("", append([]string{"lookup"}, attrs...)...); err == nil {
+		t.Fatalf("lookup after clear got %q", got)
+	}
+	if items, _ := fake.Items(); len(items) != 0 {
+		t.Fatalf("got %d items after clear, want 0", len(items))
+	}
+}
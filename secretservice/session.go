@@ -0,0 +1,119 @@
+package secretservice
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"math/big"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Algorithms of the sessions used to transfer secrets.
+const (
+	algorithmPlain = "plain"
+	algorithmDH    = "dh-ietf1024-sha256-aes128-cbc-pkcs7"
+)
+
+// dhPrime is the 1024 bit prime of the second Oakley group, RFC 2409. The generator is 2.
+var dhPrime, _ = new(big.Int).SetString(
+	"FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD1"+
+		"29024E088A67CC74020BBEA63B139B22514A08798E3404DD"+
+		"EF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245"+
+		"E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED"+
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE65381"+
+		"FFFFFFFFFFFFFFFF", 16)
+
+// session encrypts the secrets sent to and decrypts the secrets received from a client. A
+// session without a key transfers secrets in plain text.
+type session struct {
+	owner string // unique bus name of the client
+	key   []byte // aes key, nil for plain sessions
+}
+
+// newDHSession agrees on a key with the client public key and returns the session and the
+// public key to send to the client.
+func newDHSession(owner string, clientPublic []byte) (*session, []byte, error) {
+	private, err := rand.Int(rand.Reader, new(big.Int).Sub(dhPrime, big.NewInt(2)))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "generate private key")
+	}
+	private.Add(private, big.NewInt(1))
+
+	peer := new(big.Int).SetBytes(clientPublic)
+	if peer.Cmp(big.NewInt(1)) <= 0 || peer.Cmp(new(big.Int).Sub(dhPrime, big.NewInt(1))) >= 0 {
+		return nil, nil, errors.New("invalid public key")
+	}
+
+	public := new(big.Int).Exp(big.NewInt(2), private, dhPrime)
+	shared := pad(new(big.Int).Exp(peer, private, dhPrime).Bytes(), len(dhPrime.Bytes()))
+
+	key := make([]byte, 16)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, nil, nil), key); err != nil {
+		return nil, nil, errors.Wrap(err, "derive key")
+	}
+
+	return &session{owner: owner, key: key}, pad(public.Bytes(), len(dhPrime.Bytes())), nil
+}
+
+// encrypt returns the parameters and the value of a secret.
+func (s *session) encrypt(secret []byte) ([]byte, []byte, error) {
+	if s.key == nil {
+		return []byte{}, append([]byte{}, secret...), nil
+	}
+
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "create cipher")
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, nil, errors.Wrap(err, "generate iv")
+	}
+
+	// PKCS #7 padding.
+	n := aes.BlockSize - len(secret)%aes.BlockSize
+	value := append(append([]byte{}, secret...), bytes.Repeat([]byte{byte(n)}, n)...)
+
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(value, value)
+
+	return iv, value, nil
+}
+
+// decrypt returns the secret from its parameters and value.
+func (s *session) decrypt(parameters, value []byte) ([]byte, error) {
+	if s.key == nil {
+		return append([]byte{}, value...), nil
+	}
+
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, errors.Wrap(err, "create cipher")
+	}
+	if len(parameters) != aes.BlockSize || len(value) == 0 || len(value)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid secret")
+	}
+
+	secret := make([]byte, len(value))
+	cipher.NewCBCDecrypter(block, parameters).CryptBlocks(secret, value)
+
+	n := int(secret[len(secret)-1])
+	if n == 0 || n > aes.BlockSize || !bytes.Equal(secret[len(secret)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, errors.New("invalid padding")
+	}
+
+	return secret[:len(secret)-n], nil
+}
+
+// pad prepends zeros to b up to the size.
+func pad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
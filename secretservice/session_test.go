@@ -0,0 +1,138 @@
+package secretservice
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"math/big"
+	"testing"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// clientDH is the client side of a dh-ietf1024-sha256-aes128-cbc-pkcs7 session, as libsecret
+// implements it.
+type clientDH struct {
+	private *big.Int
+	public  []byte
+}
+
+func newClientDH(t *testing.T) clientDH {
+	private, err := rand.Int(rand.Reader, dhPrime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clientDH{private: private, public: new(big.Int).Exp(big.NewInt(2), private, dhPrime).Bytes()}
+}
+
+// session returns the session using the key agreed on with the server public key.
+func (c clientDH) session(t *testing.T, serverPublic []byte) *session {
+	shared := new(big.Int).Exp(new(big.Int).SetBytes(serverPublic), c.private, dhPrime).Bytes()
+	shared = append(make([]byte, 128-len(shared)), shared...)
+
+	key := make([]byte, 16)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, nil, nil), key); err != nil {
+		t.Fatal(err)
+	}
+	return &session{key: key}
+}
+
+func TestDHSession(t *testing.T) {
+	client := newClientDH(t)
+
+	server, serverPublic, err := newDHSession(":1.1", client.public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(serverPublic) != 128 {
+		t.Fatalf("got a %d byte public key, want 128", len(serverPublic))
+	}
+	clientSession := client.session(t, serverPublic)
+	if !bytes.Equal(clientSession.key, server.key) {
+		t.Fatal("keys differ")
+	}
+
+	for _, secret := range []string{"", "password", "0123456789abcdef", "a secret longer than one block"} {
+		parameters, value, err := server.encrypt([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(parameters) != aes.BlockSize || len(value)%aes.BlockSize != 0 || len(value) <= len(secret) {
+			t.Fatalf("encrypt %q: got %d byte iv and %d byte value", secret, len(parameters), len(value))
+		}
+		if secret != "" && bytes.Contains(value, []byte(secret)) {
+			t.Fatalf("encrypt %q: value contains the secret", secret)
+		}
+
+		got, err := clientSession.decrypt(parameters, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != secret {
+			t.Fatalf("got %q, want %q", got, secret)
+		}
+	}
+}
+
+func TestDHSessionInvalidPublicKey(t *testing.T) {
+	pMinus1 := new(big.Int).Sub(dhPrime, big.NewInt(1)).Bytes()
+	for _, public := range [][]byte{nil, {1}, pMinus1, dhPrime.Bytes()} {
+		if _, _, err := newDHSession(":1.1", public); err == nil {
+			t.Errorf("public key %x accepted", public)
+		}
+	}
+}
+
+func TestDecryptInvalid(t *testing.T) {
+	s := &session{key: bytes.Repeat([]byte{1}, 16)}
+	iv := make([]byte, aes.BlockSize)
+
+	// A block with invalid padding.
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := bytes.Repeat([]byte{0}, aes.BlockSize)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(value, value)
+
+	tests := []struct {
+		name              string
+		parameters, value []byte
+	}{
+		{"short iv", iv[:8], value},
+		{"empty value", iv, nil},
+		{"partial block", iv, value[:10]},
+		{"invalid padding", iv, value},
+	}
+	for _, test := range tests {
+		if _, err := s.decrypt(test.parameters, test.value); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestPlainSession(t *testing.T) {
+	s := &session{}
+	parameters, value, err := s.encrypt([]byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parameters) != 0 || string(value) != "password" {
+		t.Fatalf("got %x, %q", parameters, value)
+	}
+	if got, err := s.decrypt(nil, value); err != nil || string(got) != "password" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+}
+
+func TestPad(t *testing.T) {
+	if got := pad([]byte{1, 2}, 4); !bytes.Equal(got, []byte{0, 0, 1, 2}) {
+		t.Errorf("got %x", got)
+	}
+	if got := pad([]byte{1, 2, 3}, 2); !bytes.Equal(got, []byte{1, 2, 3}) {
+		t.Errorf("got %x", got)
+	}
+}
@@ -0,0 +1,83 @@
+// Package securemem holds secrets such as the master password in memory that is locked into
+// ram, excluded from core dumps and overwritten when no longer needed.
+package securemem
+
+import "github.com/michalnicp/1pass/logging"
+
+// release frees the memory of a destroyed buffer. Tests replace it to check the buffer was
+// wiped first.
+var release = free
+
+// Buffer is a fixed size buffer of secret bytes.
+type Buffer struct {
+	b       []byte
+	lockErr error
+}
+
+// New returns a zeroed buffer of size bytes. The buffer must be destroyed when no longer
+// needed. Memory that can't be locked, eg. because RLIMIT_MEMLOCK is too low, is still
+// used but may be swapped, see LockError.
+func New(size int) (*Buffer, error) {
+	b, err := alloc(size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Buffer{b: b, lockErr: lock(b)}, nil
+}
+
+// Copy returns a new buffer containing a copy of b.
+func Copy(b []byte) (*Buffer, error) {
+	buf, err := New(len(b))
+	if err != nil {
+		return nil, err
+	}
+	copy(buf.b, b)
+	return buf, nil
+}
+
+// Bytes returns the contents of the buffer. The slice must not be used after the buffer is
+// destroyed.
+func (b *Buffer) Bytes() []byte {
+	if b == nil {
+		return nil
+	}
+	return b.b
+}
+
+// LockError returns why the buffer couldn't be locked into ram and excluded from core
+// dumps, or nil if it was.
+func (b *Buffer) LockError() error {
+	if b == nil {
+		return nil
+	}
+	return b.lockErr
+}
+
+// Wipe overwrites the contents of the buffer with zeros.
+func (b *Buffer) Wipe() {
+	if b == nil {
+		return
+	}
+	Wipe(b.b)
+}
+
+// Destroy wipes the buffer and releases its memory.
+func (b *Buffer) Destroy() {
+	if b == nil || b.b == nil {
+		return
+	}
+
+	Wipe(b.b)
+	if err := release(b.b); err != nil {
+		logging.Warn("free secure memory", "err", err)
+	}
+	b.b = nil
+}
+
+// Wipe overwrites b with zeros.
+func Wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,54 @@
+package securemem
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// DisableCoreDumps prevents the process from dumping core and other processes of the same
+// user from attaching to it.
+func DisableCoreDumps() error {
+	return unix.Prctl(unix.PR_SET_DUMPABLE, 0, 0, 0, 0)
+}
+
+// alloc maps size bytes of anonymous memory, so the memory isn't moved or reused by the go
+// runtime.
+func alloc(size int) ([]byte, error) {
+	if size == 0 {
+		return []byte{}, nil
+	}
+
+	b, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANON)
+	if err != nil {
+		return nil, errors.Wrap(err, "mmap")
+	}
+
+	return b, nil
+}
+
+// lock locks the memory into ram and excludes it from core dumps.
+func lock(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+
+	if err := unix.Madvise(b, unix.MADV_DONTDUMP); err != nil {
+		return errors.Wrap(err, "madvise")
+	}
+
+	if err := unix.Mlock(b); err != nil {
+		return errors.Wrap(err, "mlock")
+	}
+
+	return nil
+}
+
+func free(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+
+	unix.Munlock(b)
+
+	return unix.Munmap(b)
+}
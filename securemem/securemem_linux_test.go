@@ -0,0 +1,43 @@
+package securemem
+
+import (
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestLockError(t *testing.T) {
+	buf, err := New(4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := buf.LockError(); err != nil {
+		t.Logf("lock with the current limit: %v", err)
+	}
+	buf.Destroy()
+
+	if os.Geteuid() == 0 {
+		t.Skip("root can lock memory regardless of RLIMIT_MEMLOCK")
+	}
+
+	var limit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_MEMLOCK, &limit); err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Setrlimit(unix.RLIMIT_MEMLOCK, &limit)
+
+	if err := unix.Setrlimit(unix.RLIMIT_MEMLOCK, &unix.Rlimit{Cur: 0, Max: limit.Max}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err = New(4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buf.Destroy()
+
+	if buf.LockError() == nil {
+		t.Fatal("expected a lock error with RLIMIT_MEMLOCK 0")
+	}
+}
@@ -0,0 +1,20 @@
+// +build !linux
+
+package securemem
+
+// DisableCoreDumps is not supported on this platform.
+func DisableCoreDumps() error {
+	return nil
+}
+
+func alloc(size int) ([]byte, error) {
+	return make([]byte, size), nil
+}
+
+func lock(b []byte) error {
+	return nil
+}
+
+func free(b []byte) error {
+	return nil
+}
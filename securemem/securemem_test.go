@@ -0,0 +1,83 @@
+package securemem
+
+import (
+	"bytes"
+	"testing"
+)
+
+func zeroed(b []byte) bool {
+	return bytes.Equal(b, make([]byte, len(b)))
+}
+
+func TestNew(t *testing.T) {
+	buf, err := New(4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buf.Destroy()
+
+	if len(buf.Bytes()) != 4096 || !zeroed(buf.Bytes()) {
+		t.Fatal("new buffer not zeroed")
+	}
+}
+
+func TestWipe(t *testing.T) {
+	b := []byte("master password")
+	Wipe(b)
+	if !zeroed(b) {
+		t.Fatalf("got %q after Wipe", b)
+	}
+
+	buf, err := Copy([]byte("master password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buf.Destroy()
+
+	buf.Wipe()
+	if !zeroed(buf.Bytes()) {
+		t.Fatalf("got %q after Buffer.Wipe", buf.Bytes())
+	}
+}
+
+func TestCopy(t *testing.T) {
+	src := []byte("secret key")
+	buf, err := Copy(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buf.Destroy()
+
+	src[0] = 'S'
+	if string(buf.Bytes()) != "secret key" {
+		t.Fatalf("copy shares memory with the source: %q", buf.Bytes())
+	}
+}
+
+func TestDestroy(t *testing.T) {
+	var released []byte
+	release = func(b []byte) error {
+		released = append([]byte{}, b...)
+		return free(b)
+	}
+	defer func() { release = free }()
+
+	buf, err := Copy([]byte("master password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Destroy()
+	if len(released) != len("master password") || !zeroed(released) {
+		t.Fatalf("released %q, want it wiped", released)
+	}
+	if buf.Bytes() != nil {
+		t.Fatal("destroyed buffer still has bytes")
+	}
+
+	// Destroying twice and destroying nil buffers is safe.
+	buf.Destroy()
+	var nilBuf *Buffer
+	nilBuf.Destroy()
+	nilBuf.Wipe()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"time"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/michalnicp/1pass/ipc"
+	"github.com/pkg/errors"
+)
+
+// service implements the methods called by other processes over the ipc socket. Methods
+// are called from other goroutines, changes to the ui are queued for the main thread.
+type service struct {
+	state  *UIState
+	window *glfw.Window
+}
+
+// Show shows the window.
+func (s *service) Show(args ipc.Empty, reply *ipc.Empty) error {
+	s.state.queue(s.window.Show)
+	glfw.PostEmptyEvent()
+	return nil
+}
+
+// Search shows the window searching for the query.
+func (s *service) Search(query string, reply *ipc.Empty) error {
+	s.state.queue(func() {
+		s.state.setSearchQuery(query)
+		s.window.Show()
+	})
+	glfw.PostEmptyEvent()
+	return nil
+}
+
+// Lock locks the session.
+func (s *service) Lock(args ipc.Empty, reply *ipc.Empty) error {
+	s.state.queue(s.state.lock)
+	glfw.PostEmptyEvent()
+	return nil
+}
+
+// instanceAction is what the arguments of the ui ask the running instance to do.
+type instanceAction struct {
+	method string // Show or Search
+	query  string
+}
+
+// parseInstanceAction parses the arguments left after the flags, show or search <query>. The
+// window is shown if there are none. lock is a subcommand, see lockSession.
+func parseInstanceAction(args []string) (instanceAction, error) {
+	if len(args) == 0 {
+		return instanceAction{method: "Show"}, nil
+	}
+
+	switch {
+	case args[0] == "show" && len(args) == 1:
+		return instanceAction{method: "Show"}, nil
+	case args[0] == "search" && len(args) == 2:
+		return instanceAction{method: "Search", query: args[1]}, nil
+	case args[0] == "search":
+		return instanceAction{}, errors.New("search takes a single query")
+	}
+
+	return instanceAction{}, errors.Errorf("unknown command %q", args[0])
+}
+
+// forward asks the running instance to perform the action. The instance may have just
+// started, so connecting is retried for a moment.
+func (a instanceAction) forward() error {
+	var args interface{} = ipc.Empty{}
+	if a.method == "Search" {
+		args = a.query
+	}
+
+	var err error
+	for i := 0; i < 10; i++ {
+		if err = ipc.Call(ipc.SocketPath(), a.method, args, &ipc.Empty{}); err == nil {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return errors.Wrap(err, "forward to the running 1pass")
+}
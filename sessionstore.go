@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/michalnicp/1pass/op"
+)
+
+// sessionStore owns the sessions of the accounts signed in to and which of them is current.
+// The current session is replaced by signing in, switching accounts and signing out on one
+// goroutine while the ui, the ipc service and the agents use it on others.
+type sessionStore struct {
+	mu       sync.RWMutex
+	session  *op.Session
+	accounts map[string]*op.Session // by accountKey
+	watchers []func(*op.Session)
+}
+
+// newSessionStore returns a store holding the session, which may be nil.
+func newSessionStore(session *op.Session) *sessionStore {
+	s := &sessionStore{session: session, accounts: make(map[string]*op.Session)}
+	if session != nil {
+		s.accounts[accountKey(session.SigninAddress, session.Email)] = session
+	}
+	return s
+}
+
+// accountKey identifies the account of a session by sign in address and email.
+func accountKey(signinAddress, email string) string {
+	return strings.TrimPrefix(signinAddress, "https://") + " " + email
+}
+
+// Get returns the current session or nil if not signed in.
+func (s *sessionStore) Get() *op.Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.session
+}
+
+// Set makes the session current and calls the watchers if it changed. The session replaces
+// the one kept for its account. Setting nil shows no account, the sessions of the accounts
+// are kept. Watchers are called on the goroutine calling Set, after the store is unlocked.
+func (s *sessionStore) Set(session *op.Session) {
+	s.mu.Lock()
+	if session != nil {
+		s.accounts[accountKey(session.SigninAddress, session.Email)] = session
+	}
+	s.setLocked(session)
+}
+
+// Switch makes the session kept for the account current. It reports false if the account
+// isn't signed in.
+func (s *sessionStore) Switch(signinAddress, email string) bool {
+	s.mu.Lock()
+	session, ok := s.accounts[accountKey(signinAddress, email)]
+	if !ok {
+		s.mu.Unlock()
+		return false
+	}
+	s.setLocked(session)
+	return true
+}
+
+// Remove forgets the session of a signed out account. No account is current if it was.
+func (s *sessionStore) Remove(session *op.Session) {
+	s.mu.Lock()
+	key := accountKey(session.SigninAddress, session.Email)
+	if s.accounts[key] == session {
+		delete(s.accounts, key)
+	}
+	if s.session != session {
+		s.mu.Unlock()
+		return
+	}
+	s.setLocked(nil)
+}
+
+// All returns the sessions of all accounts signed in to.
+func (s *sessionStore) All() []*op.Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions := make([]*op.Session, 0, len(s.accounts))
+	for _, session := range s.accounts {
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// setLocked replaces the current session, unlocks the store and calls the watchers if it
+// changed. s.mu must be held.
+func (s *sessionStore) setLocked(session *op.Session) {
+	if s.session == session {
+		s.mu.Unlock()
+		return
+	}
+	s.session = session
+	watchers := s.watchers
+	s.mu.Unlock()
+
+	for _, f := range watchers {
+		f(session)
+	}
+}
+
+// Watch calls f with the new session every time the current session changes.
+func (s *sessionStore) Watch(f func(*op.Session)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watchers = append(s.watchers[:len(s.watchers):len(s.watchers)], f)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/michalnicp/1pass/op"
+)
+
+func newTestSession(t *testing.T, signinAddress, email string) *op.Session {
+	session, err := op.NewSession(signinAddress, email, []byte("secret-key"), "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func TestSessionStoreSwitch(t *testing.T) {
+	first := newTestSession(t, "https://example.1password.com", "user@example.com")
+	second := newTestSession(t, "my.1password.com", "user@example.com")
+
+	store := newSessionStore(first)
+
+	var changes []*op.Session
+	store.Watch(func(session *op.Session) {
+		changes = append(changes, session)
+	})
+
+	// Signing in to another account keeps the first one.
+	store.Set(nil)
+	store.Set(second)
+	if len(store.All()) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(store.All()))
+	}
+
+	if !store.Switch("example.1password.com", "user@example.com") || store.Get() != first {
+		t.Fatal("switch to the first account failed")
+	}
+	if store.Switch("other.1password.com", "user@example.com") || store.Get() != first {
+		t.Fatal("switched to an account not signed in")
+	}
+
+	// Switching to the current account changes nothing.
+	store.Switch("https://example.1password.com", "user@example.com")
+
+	store.Remove(first)
+	if store.Get() != nil || len(store.All()) != 1 {
+		t.Fatalf("first session not removed: current %v, %d sessions", store.Get(), len(store.All()))
+	}
+
+	// Removing a session that isn't current keeps the current one.
+	store.Switch("my.1password.com", "user@example.com")
+	store.Remove(first)
+	if store.Get() != second {
+		t.Fatal("current session removed")
+	}
+
+	want := []*op.Session{nil, second, first, nil, second}
+	if len(changes) != len(want) {
+		t.Fatalf("got %d changes, want %d", len(changes), len(want))
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d got %p, want %p", i, changes[i], want[i])
+		}
+	}
+}
+
+// TestSessionStoreConcurrent signs in, switches accounts and signs out while other goroutines
+// read the current session. Run with -race.
+func TestSessionStoreConcurrent(t *testing.T) {
+	first := newTestSession(t, "example.1password.com", "user@example.com")
+	second := newTestSession(t, "my.1password.com", "user@example.com")
+
+	store := newSessionStore(nil)
+
+	var mu sync.Mutex
+	var current *op.Session
+	store.Watch(func(session *op.Session) {
+		mu.Lock()
+		current = session
+		mu.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(4)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			store.Set(first)
+			store.Set(second)
+			store.Switch("example.1password.com", "user@example.com")
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			store.Remove(second)
+			store.Set(nil)
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if session := store.Get(); session != nil && session != first && session != second {
+					t.Error("unknown session")
+				}
+				for _, session := range store.All() {
+					session.Valid()
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// Watchers of concurrent changes may run out of order, the last change is seen.
+	store.Set(first)
+	store.Set(second)
+	mu.Lock()
+	defer mu.Unlock()
+	if current != store.Get() {
+		t.Fatal("watcher missed the last change")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/golang-ui/nuklear/nk"
+	"github.com/michalnicp/1pass/config"
+	"github.com/michalnicp/1pass/logging"
+)
+
+// showSettings opens the settings view editing a copy of the config file, without the flags
+// given on the command line.
+func (s *UIState) showSettings() {
+	c := *s.fileConfig
+	s.settings = &c
+}
+
+// saveSettings writes the edited settings to the config file and applies them with the flags.
+func (s *UIState) saveSettings(window *glfw.Window) {
+	c, err := withFlags(s.settings)
+	if err == nil {
+		err = s.settings.Save(s.configPath)
+	}
+	if err != nil {
+		logging.Error("save settings", "err", err)
+		s.statusText = fmt.Sprintf("save settings: %v", err)
+		return
+	}
+
+	s.fileConfig = s.settings
+	s.applyConfig(window, c)
+	s.settings = nil
+	s.statusText = "settings saved"
+}
+
+// Settings draws the settings view with the common options of the config file.
+func Settings(window *glfw.Window, ctx *nk.Context, state *UIState) {
+	c := state.settings
+
+	width, height := window.GetSize()
+	bounds := nk.NkRect(0, 0, float32(width), float32(height))
+	if nk.NkBegin(ctx, "settings", bounds, 0) > 0 {
+		nk.NkLayoutRowDynamic(ctx, 0, 1)
+		nk.NkLabel(ctx, "Settings, press escape to go back", nk.TextLeft)
+
+		nk.NkLayoutRowDynamic(ctx, 0, 2)
+		c.Width = int(nk.NkPropertyi(ctx, "#Width", 100, int32(c.Width), 4000, 10, 1))
+		c.Height = int(nk.NkPropertyi(ctx, "#Height", 100, int32(c.Height), 4000, 10, 1))
+
+		nk.NkLayoutRowDynamic(ctx, 0, 1)
+		minutes := int32(c.LockTimeout.Duration / time.Minute)
+		if m := nk.NkPropertyi(ctx, "#Lock after minutes (0 never)", 0, minutes, 24*60, 1, 1); m != minutes {
+			c.LockTimeout.Duration = time.Duration(m) * time.Minute
+		}
+		c.Search.Fuzziness = int(nk.NkPropertyi(ctx, "#Search typos", 0, int32(c.Search.Fuzziness), 2, 1, 1))
+
+		var offline int32
+		if c.Offline {
+			offline = 1
+		}
+		c.Offline = nk.NkCheckLabel(ctx, "Keep items for offline use", offline) > 0
+
+		nk.NkLabel(ctx, "Clipboard", nk.TextLeft)
+		nk.NkLayoutRowDynamic(ctx, 0, int32(len(config.ClipboardTools)))
+		for _, tool := range config.ClipboardTools {
+			if selectLabel(ctx, tool, c.Clipboard == tool) {
+				c.Clipboard = tool
+			}
+		}
+
+		nk.NkLayoutRowDynamic(ctx, 0, 1)
+		nk.NkLabel(ctx, "Log level", nk.TextLeft)
+		nk.NkLayoutRowDynamic(ctx, 0, int32(len(config.LogLevels)))
+		for _, level := range config.LogLevels {
+			if selectLabel(ctx, level, c.LogLevel == level) {
+				c.LogLevel = level
+			}
+		}
+
+		nk.NkLayoutRowDynamic(ctx, 0, 1)
+		nk.NkLabel(ctx, "Tray (after restart)", nk.TextLeft)
+		nk.NkLayoutRowDynamic(ctx, 0, int32(len(config.TrayBackends)))
+		for _, backend := range config.TrayBackends {
+			if selectLabel(ctx, backend, c.Tray == backend) {
+				c.Tray = backend
+			}
+		}
+
+		// Padding.
+		nk.NkLayoutRowStatic(ctx, 10, 0, 0)
+
+		nk.NkLayoutRowDynamic(ctx, 30, 1)
+		if nk.NkButtonLabel(ctx, "Save") > 0 {
+			state.saveSettings(window)
+		}
+
+		nk.NkLayoutRowDynamic(ctx, 0, 1)
+		nk.NkLabel(ctx, state.statusText, nk.TextLeft)
+
+		nk.NkEnd(ctx)
+	}
+}
+
+// selectLabel draws a selectable label and reports whether it is selected.
+func selectLabel(ctx *nk.Context, label string, selected bool) bool {
+	var value int32
+	if selected {
+		value = 1
+	}
+	return nk.NkSelectLabel(ctx, label, nk.TextCentered, value) > 0
+}
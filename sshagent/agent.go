@@ -0,0 +1,381 @@
+package sshagent
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michalnicp/1pass/ipc"
+	"github.com/michalnicp/1pass/logging"
+	"github.com/michalnicp/1pass/op"
+	"github.com/michalnicp/1pass/securemem"
+	errors2 "github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+var (
+
+	// ErrReadOnly is returned when a client tries to add or remove keys.
+	ErrReadOnly = errors.New("agent is read only")
+
+	// ErrDenied is returned when the user denies a signature request.
+	ErrDenied = errors.New("signature request denied")
+
+	// ErrLocked is returned when the agent is locked.
+	ErrLocked = errors.New("agent is locked")
+
+	// ErrKeyNotFound is returned when a signature is requested for an unknown key.
+	ErrKeyNotFound = errors.New("key not found")
+)
+
+// DocumentTag marks the document items holding a private key. Other documents are never
+// downloaded.
+const DocumentTag = "ssh"
+
+// Agent is an ssh agent serving the private keys of SSH Key and document items. Keys can't
+// be added or removed by clients and every signature must be confirmed by the user.
+type Agent struct {
+
+	// Session returns the current session, or nil if not signed in.
+	Session func() *op.Session
+
+	// Vaults restricts the keys to the items in these vaults, given by name or uuid. Keys from
+	// all vaults are served if empty.
+	Vaults []string
+
+	// Confirm asks the user to allow a signature using the key. It blocks until the user
+	// answers.
+	Confirm func(text string) bool
+
+	// Reveal is called with every item whose private key is used to sign if set.
+	Reveal func(item *op.Item)
+
+	mu         sync.Mutex
+	passphrase []byte               // set when locked with ssh-add -x
+	publicKeys map[string]publicKey // by item uuid
+}
+
+// key is the public key of an item.
+type key struct {
+	item op.Item
+	pub  ssh.PublicKey
+}
+
+// publicKey is the cached public key of an item, so listing keys doesn't fetch the private
+// keys. The private key is only fetched to sign.
+type publicKey struct {
+	updatedAt time.Time
+	pub       ssh.PublicKey // nil if the item holds no private key
+}
+
+// ListenAndServe listens on the unix socket at path and serves agent requests. The directory
+// of the socket is created if needed and must only be accessible by the user, see
+// ipc.PrivateDir.
+func (a *Agent) ListenAndServe(path string) error {
+	if err := ipc.PrivateDir(filepath.Dir(path)); err != nil {
+		return errors2.Wrap(err, "socket directory")
+	}
+
+	// Remove a socket left over from a previous run.
+	os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return errors2.Wrap(err, "listen")
+	}
+	defer l.Close()
+
+	if err := os.Chmod(path, 0600); err != nil {
+		return errors2.Wrap(err, "chmod socket")
+	}
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return errors2.Wrap(err, "accept")
+		}
+
+		go func() {
+			defer conn.Close()
+			if err := agent.ServeAgent(a, conn); err != nil && err != io.EOF {
+				logging.Error("serve ssh agent", "err", err)
+			}
+		}()
+	}
+}
+
+// List returns the public keys of the items.
+func (a *Agent) List() ([]*agent.Key, error) {
+	if a.locked() {
+		return nil, nil
+	}
+
+	keys, err := a.keys()
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*agent.Key
+	for _, k := range keys {
+		list = append(list, &agent.Key{
+			Format:  k.pub.Type(),
+			Blob:    k.pub.Marshal(),
+			Comment: k.item.Overview.Title,
+		})
+	}
+
+	return list, nil
+}
+
+// Sign signs data with the key after the user confirms the request.
+func (a *Agent) Sign(pub ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	return a.SignWithFlags(pub, data, 0)
+}
+
+// SignWithFlags signs data with the key using the algorithm requested by the flags after the
+// user confirms the request.
+func (a *Agent) SignWithFlags(pub ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	if a.locked() {
+		return nil, ErrLocked
+	}
+
+	keys, err := a.keys()
+	if err != nil {
+		return nil, err
+	}
+
+	blob := pub.Marshal()
+	for _, k := range keys {
+		if !bytes.Equal(k.pub.Marshal(), blob) {
+			continue
+		}
+
+		text := fmt.Sprintf("Allow ssh to use the key %q (%s)?", k.item.Overview.Title, ssh.FingerprintSHA256(pub))
+		if a.Confirm == nil || !a.Confirm(text) {
+			return nil, ErrDenied
+		}
+
+		signer, err := privateKey(a.Session(), k.item)
+		if err != nil {
+			return nil, err
+		}
+		if signer == nil || !bytes.Equal(signer.PublicKey().Marshal(), blob) {
+			return nil, ErrKeyNotFound
+		}
+
+		if a.Reveal != nil {
+			a.Reveal(&k.item)
+		}
+
+		if signer, ok := signer.(ssh.AlgorithmSigner); ok && pub.Type() == ssh.KeyAlgoRSA {
+			switch {
+			case flags&agent.SignatureFlagRsaSha256 != 0:
+				return signer.SignWithAlgorithm(rand.Reader, data, ssh.KeyAlgoRSASHA256)
+			case flags&agent.SignatureFlagRsaSha512 != 0:
+				return signer.SignWithAlgorithm(rand.Reader, data, ssh.KeyAlgoRSASHA512)
+			}
+		}
+
+		return signer.Sign(rand.Reader, data)
+	}
+
+	return nil, ErrKeyNotFound
+}
+
+// Signers is not supported, signatures always go through Sign so they can be confirmed.
+func (a *Agent) Signers() ([]ssh.Signer, error) {
+	return nil, ErrReadOnly
+}
+
+func (a *Agent) Add(key agent.AddedKey) error { return ErrReadOnly }
+
+func (a *Agent) Remove(key ssh.PublicKey) error { return ErrReadOnly }
+
+func (a *Agent) RemoveAll() error { return ErrReadOnly }
+
+// Lock hides the keys until the agent is unlocked with the same passphrase.
+func (a *Agent) Lock(passphrase []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.passphrase != nil {
+		return ErrLocked
+	}
+	a.passphrase = append([]byte{}, passphrase...)
+
+	return nil
+}
+
+// Unlock undoes Lock.
+func (a *Agent) Unlock(passphrase []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.passphrase == nil {
+		return errors.New("agent is not locked")
+	}
+	if subtle.ConstantTimeCompare(a.passphrase, passphrase) != 1 {
+		return errors.New("incorrect passphrase")
+	}
+	a.passphrase = nil
+
+	return nil
+}
+
+func (a *Agent) Extension(extensionType string, contents []byte) ([]byte, error) {
+	return nil, agent.ErrExtensionUnsupported
+}
+
+func (a *Agent) locked() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.passphrase != nil
+}
+
+// keys returns the public keys of the SSH Key items and the document items tagged with
+// DocumentTag in the allowed vaults. Private keys are only fetched for new or changed items,
+// items that don't contain an unencrypted private key are skipped.
+func (a *Agent) keys() ([]key, error) {
+	session := a.Session()
+	if !session.Valid() {
+		return nil, nil
+	}
+
+	vaults, err := a.allowedVaults(session)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := session.ListItems()
+	if err != nil {
+		return nil, errors2.Wrap(err, "list items")
+	}
+
+	a.mu.Lock()
+	cached := a.publicKeys
+	a.mu.Unlock()
+
+	var keys []key
+	publicKeys := make(map[string]publicKey)
+	for _, item := range items {
+		if vaults != nil && !vaults[item.VaultUUID] {
+			continue
+		}
+		if item.TemplateUUID != op.TemplateSSHKey &&
+			!(item.TemplateUUID == op.TemplateDocument && item.HasTag(DocumentTag)) {
+			continue
+		}
+
+		public, ok := cached[item.UUID]
+		if !ok || !public.updatedAt.Equal(item.UpdatedAt) {
+			signer, err := privateKey(session, item)
+			if err != nil {
+				return nil, err
+			}
+
+			public = publicKey{updatedAt: item.UpdatedAt}
+			if signer != nil {
+				public.pub = signer.PublicKey()
+			}
+		}
+		publicKeys[item.UUID] = public
+
+		if public.pub != nil {
+			keys = append(keys, key{item: item, pub: public.pub})
+		}
+	}
+
+	a.mu.Lock()
+	a.publicKeys = publicKeys
+	a.mu.Unlock()
+
+	return keys, nil
+}
+
+// privateKey fetches and parses the private key of the item. It returns nil if the item
+// doesn't contain an unencrypted private key.
+func privateKey(session *op.Session, item op.Item) (ssh.Signer, error) {
+	var pem []byte
+	switch item.TemplateUUID {
+	case op.TemplateSSHKey:
+		details, err := session.GetItem(item.UUID)
+		if err != nil {
+			return nil, errors2.Wrap(err, "get item")
+		}
+		pem = privateKeyField(details)
+	case op.TemplateDocument:
+		document, err := session.GetDocument(item.UUID)
+		if err != nil {
+			return nil, errors2.Wrap(err, "get document")
+		}
+		defer securemem.Wipe(document)
+		pem = document
+	}
+
+	if !bytes.Contains(pem, []byte("PRIVATE KEY-----")) {
+		return nil, nil
+	}
+
+	signer, err := ssh.ParsePrivateKey(pem)
+	if err != nil {
+		logging.Warn("parse private key", "item", item.UUID, "err", err)
+		return nil, nil
+	}
+
+	return signer, nil
+}
+
+// allowedVaults returns the uuids of the allowed vaults, or nil if all vaults are allowed.
+func (a *Agent) allowedVaults(session *op.Session) (map[string]bool, error) {
+	if len(a.Vaults) == 0 {
+		return nil, nil
+	}
+
+	vaults, err := session.ListVaults()
+	if err != nil {
+		return nil, errors2.Wrap(err, "list vaults")
+	}
+
+	allowed := make(map[string]bool)
+	for _, name := range a.Vaults {
+		for _, vault := range vaults {
+			if vault.UUID == name || strings.EqualFold(vault.Name, name) {
+				allowed[vault.UUID] = true
+			}
+		}
+	}
+
+	return allowed, nil
+}
+
+// privateKeyField returns the first field of the item containing a pem encoded private key.
+func privateKeyField(item *op.Item) []byte {
+	if item.Details == nil {
+		return nil
+	}
+
+	for _, field := range item.Details.Fields {
+		if strings.Contains(field.Value, "PRIVATE KEY-----") {
+			return []byte(field.Value)
+		}
+	}
+	for _, section := range item.Details.Sections {
+		for _, field := range section.Fields {
+			if strings.Contains(field.Value, "PRIVATE KEY-----") {
+				return []byte(field.Value)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package sshagent
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/michalnicp/1pass/op"
+	"github.com/michalnicp/1pass/op/optest"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestMain(m *testing.M) {
+	optest.Main(m)
+}
+
+func TestKeys(t *testing.T) {
+	keyItem := op.Item{UUID: "key", TemplateUUID: op.TemplateSSHKey, Details: &op.Details{
+		Sections: []op.Section{{Fields: []op.SectionField{{Value: newKey(t)}}}},
+	}}
+	keyItem.Overview.Title = "key item"
+
+	tagged := op.Item{UUID: "tagged", TemplateUUID: op.TemplateDocument}
+	tagged.Overview.Title = "id_ed25519"
+	tagged.Overview.Tags = []string{DocumentTag}
+
+	untagged := op.Item{UUID: "untagged", TemplateUUID: op.TemplateDocument}
+	untagged.Overview.Title = "other key"
+
+	login := op.Item{UUID: "login", TemplateUUID: op.TemplateLogin}
+
+	documents := []optest.Document{
+		{UUID: "tagged", Data: []byte(newKey(t))},
+		{UUID: "untagged", Data: []byte(newKey(t))},
+	}
+
+	fake, err := optest.New(nil, []op.Item{keyItem, tagged, untagged, login}, documents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	session, err := fake.Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Unlock([]byte(optest.MasterPassword)); err != nil {
+		t.Fatal(err)
+	}
+
+	var confirmed string
+	var revealed []string
+	a := Agent{
+		Session: func() *op.Session { return session },
+		Confirm: func(text string) bool {
+			confirmed = text
+			return true
+		},
+		Reveal: func(item *op.Item) {
+			revealed = append(revealed, item.Overview.Title)
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		keys, err := a.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 2 || keys[0].Comment != "key item" || keys[1].Comment != "id_ed25519" {
+			t.Fatalf("got keys %v", keys)
+		}
+	}
+
+	// The private keys are only fetched once and untagged documents never.
+	if n := countCalls(t, fake, "get document untagged"); n != 0 {
+		t.Fatalf("untagged document fetched %d times", n)
+	}
+	if n := countCalls(t, fake, "get document tagged"); n != 1 {
+		t.Fatalf("tagged document fetched %d times, want 1", n)
+	}
+
+	keys, err := a.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ssh.ParsePublicKey(keys[1].Blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := a.Sign(pub, []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.Verify([]byte("data"), sig); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(confirmed, "id_ed25519") {
+		t.Fatalf("confirmed %q", confirmed)
+	}
+
+	// Listing keys reveals nothing, signing reveals the key used.
+	if len(revealed) != 1 || revealed[0] != "id_ed25519" {
+		t.Fatalf("revealed %q", revealed)
+	}
+}
+
+func newKey(t *testing.T) string {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(private, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(block))
+}
+
+func countCalls(t *testing.T, fake *optest.Fake, command string) int {
+	calls, err := fake.Calls()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	for _, call := range calls {
+		if strings.HasPrefix(strings.Join(call.Args, " "), command+" ") {
+			n++
+		}
+	}
+	return n
+}
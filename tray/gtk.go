@@ -0,0 +1,111 @@
+package tray
+
+/*
+#cgo CFLAGS: -Wno-deprecated-declarations
+#cgo pkg-config: gtk+-3.0
+
+#include <stdlib.h>
+
+#include "gtk.h"
+#include <gtk/gtk.h>
+
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// gtkTray is a GtkStatusIcon. Gtk calls the exported callbacks, so there is only one.
+type gtkTray struct {
+	activate  func()
+	menu      []Item
+	callbacks []func() // activate callbacks of the menu items by id
+}
+
+var current *gtkTray
+
+func newGTK(activate func()) (Tray, error) {
+	if current != nil {
+		return nil, errors.New("gtk tray already created")
+	}
+
+	current = &gtkTray{activate: activate}
+	C.init()
+
+	return current, nil
+}
+
+//export activate
+func activate(widget *C.GtkWidget, data C.gpointer) {
+	if current.activate != nil {
+		current.activate()
+	}
+}
+
+//export menu_item_activate
+func menu_item_activate(widget *C.GtkWidget, data C.gpointer) {
+	id := int(C.pointer_to_int(data))
+	if id < len(current.callbacks) && current.callbacks[id] != nil {
+		current.callbacks[id]()
+	}
+}
+
+func (t *gtkTray) SetMenu(items []Item) {
+	t.callbacks = t.callbacks[:0]
+	if SameLayout(t.menu, items) {
+		t.register(items)
+	} else {
+		t.build(C.menu_reset(), items)
+		C.menu_show()
+	}
+	t.menu = items
+}
+
+// Loop runs the gtk main loop without blocking.
+func (t *gtkTray) Loop() { C.loop() }
+
+func (t *gtkTray) Close() error {
+	C.hide()
+	return nil
+}
+
+// register assigns ids to the items in the order they are built.
+func (t *gtkTray) register(items []Item) {
+	for _, item := range items {
+		if item.Label == "" {
+			continue
+		}
+		if item.Items != nil {
+			t.register(item.Items)
+			continue
+		}
+		t.callbacks = append(t.callbacks, item.Activate)
+	}
+}
+
+func (t *gtkTray) build(parent *C.GtkWidget, items []Item) {
+	for _, item := range items {
+		if item.Label == "" {
+			C.menu_append_separator(parent)
+			continue
+		}
+
+		label := C.CString(item.Label)
+		sensitive := C.gboolean(1)
+		if item.Disabled {
+			sensitive = 0
+		}
+
+		if item.Items != nil {
+			t.build(C.menu_append_submenu(parent, label, sensitive), item.Items)
+		} else {
+			C.menu_append(parent, label, C.int(len(t.callbacks)), sensitive)
+			t.callbacks = append(t.callbacks, item.Activate)
+		}
+
+		C.free(unsafe.Pointer(label))
+	}
+}
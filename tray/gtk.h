@@ -0,0 +1,72 @@
+#pragma once
+
+#include <gtk/gtk.h>
+
+extern void activate(GtkWidget *widget, gpointer data);
+extern void menu_item_activate(GtkWidget *widget, gpointer data);
+
+static GtkStatusIcon *status_icon;
+static GtkWidget *menu;
+
+static void status_icon_popup_menu(GtkStatusIcon *status_icon, guint button, guint activation_time, gpointer data) {
+    gtk_menu_popup(GTK_MENU(menu), NULL, NULL, gtk_status_icon_position_menu, status_icon, button, activation_time);
+}
+
+static void init() {
+    gtk_init(0, NULL);
+
+    menu = gtk_menu_new();
+
+    // Create system tray icon.
+    status_icon = gtk_status_icon_new_from_icon_name("1pass");
+    g_signal_connect(status_icon, "activate", G_CALLBACK(activate), NULL);
+    g_signal_connect(status_icon, "popup-menu", G_CALLBACK(status_icon_popup_menu), NULL);
+}
+
+static void loop() {
+    gtk_main_iteration_do(FALSE);
+}
+
+static void hide() {
+    gtk_status_icon_set_visible(status_icon, FALSE);
+}
+
+// menu_reset replaces the context menu with an empty one and returns it.
+static GtkWidget *menu_reset() {
+    gtk_widget_destroy(menu);
+    menu = gtk_menu_new();
+    return menu;
+}
+
+// menu_append appends an item to the menu calling menu_item_activate with the id when
+// activated.
+static GtkWidget *menu_append(GtkWidget *parent, const char *label, int id, gboolean sensitive) {
+    GtkWidget *item = gtk_menu_item_new_with_label(label);
+    gtk_widget_set_sensitive(item, sensitive);
+    gtk_menu_shell_append(GTK_MENU_SHELL(parent), item);
+    g_signal_connect(item, "activate", G_CALLBACK(menu_item_activate), GINT_TO_POINTER(id));
+    return item;
+}
+
+// menu_append_submenu appends an item opening a submenu and returns the submenu.
+static GtkWidget *menu_append_submenu(GtkWidget *parent, const char *label, gboolean sensitive) {
+    GtkWidget *item = gtk_menu_item_new_with_label(label);
+    gtk_widget_set_sensitive(item, sensitive);
+    gtk_menu_shell_append(GTK_MENU_SHELL(parent), item);
+
+    GtkWidget *submenu = gtk_menu_new();
+    gtk_menu_item_set_submenu(GTK_MENU_ITEM(item), submenu);
+    return submenu;
+}
+
+static void menu_append_separator(GtkWidget *parent) {
+    gtk_menu_shell_append(GTK_MENU_SHELL(parent), gtk_separator_menu_item_new());
+}
+
+static void menu_show() {
+    gtk_widget_show_all(menu);
+}
+
+static int pointer_to_int(gpointer p) {
+    return GPOINTER_TO_INT(p);
+}
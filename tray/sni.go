@@ -0,0 +1,455 @@
+package tray
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus"
+	"github.com/godbus/dbus/introspect"
+	"github.com/godbus/dbus/prop"
+	"github.com/michalnicp/1pass/logging"
+	"github.com/pkg/errors"
+)
+
+const (
+	itemInterface    = "org.kde.StatusNotifierItem"
+	itemPath         = "/StatusNotifierItem"
+	menuInterface    = "com.canonical.dbusmenu"
+	menuPath         = "/MenuBar"
+	watcherName      = "org.kde.StatusNotifierWatcher"
+	watcherPath      = "/StatusNotifierWatcher"
+	menuErrorName    = "com.canonical.dbusmenu.Error"
+	introspectableIf = "org.freedesktop.DBus.Introspectable"
+)
+
+// sniTray is a StatusNotifierItem with a DBusMenu, the tray protocol of KDE and of GNOME with
+// the AppIndicator extension. The menu is served over the session bus and the host draws it.
+type sniTray struct {
+	conn     *dbus.Conn
+	name     string
+	activate func()
+
+	// D-Bus methods are called from other goroutines, the callbacks they trigger run in Loop.
+	events chan func()
+
+	mu        sync.Mutex
+	menu      []Item
+	layout    menuLayout
+	revision  uint32
+	callbacks map[int32]func()
+}
+
+// menuLayout is an item of the menu and its children, (ia{sv}av) on the bus.
+type menuLayout struct {
+	ID         int32
+	Properties map[string]dbus.Variant
+	Children   []dbus.Variant
+}
+
+// menuProperties are the properties of an item, (ia{sv}) on the bus.
+type menuProperties struct {
+	ID         int32
+	Properties map[string]dbus.Variant
+}
+
+// menuEvent is an event on an item, (isvu) on the bus.
+type menuEvent struct {
+	ID        int32
+	EventID   string
+	Data      dbus.Variant
+	Timestamp uint32
+}
+
+func newSNI(activate func()) (Tray, error) {
+	conn, err := dbus.SessionBusPrivate()
+	if err != nil {
+		return nil, errors.Wrap(err, "connect to session bus")
+	}
+	if err := conn.Auth(nil); err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "authenticate to session bus")
+	}
+	if err := conn.Hello(); err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "hello session bus")
+	}
+
+	t := &sniTray{
+		conn:      conn,
+		name:      fmt.Sprintf("org.kde.StatusNotifierItem-%d-1", os.Getpid()),
+		activate:  activate,
+		events:    make(chan func(), 16),
+		callbacks: make(map[int32]func()),
+	}
+	t.layout = menuLayout{ID: 0, Properties: rootProperties()}
+
+	if err := t.export(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reply, err := conn.RequestName(t.name, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "request name")
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		conn.Close()
+		return nil, errors.Errorf("name %s is taken", t.name)
+	}
+
+	if err := t.register(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go t.watch()
+
+	return t, nil
+}
+
+// export serves the item and the menu.
+func (t *sniTray) export() error {
+	item := sniItem{t}
+	itemProps := prop.New(t.conn, itemPath, map[string]map[string]*prop.Prop{
+		itemInterface: {
+			"Category":      {Value: "ApplicationStatus"},
+			"Id":            {Value: "1pass"},
+			"Title":         {Value: "1pass"},
+			"Status":        {Value: "Active"},
+			"WindowId":      {Value: int32(0)},
+			"IconName":      {Value: "1pass"},
+			"IconThemePath": {Value: ""},
+			"Menu":          {Value: dbus.ObjectPath(menuPath)},
+			"ItemIsMenu":    {Value: false},
+		},
+	})
+	if err := t.conn.Export(item, itemPath, itemInterface); err != nil {
+		return errors.Wrap(err, "export item")
+	}
+	itemNode := &introspect.Node{
+		Name: itemPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       itemInterface,
+				Methods:    introspect.Methods(item),
+				Properties: itemProps.Introspection(itemInterface),
+			},
+		},
+	}
+	if err := t.conn.Export(introspect.NewIntrospectable(itemNode), itemPath, introspectableIf); err != nil {
+		return errors.Wrap(err, "export item introspection")
+	}
+
+	menu := dbusMenu{t}
+	menuProps := prop.New(t.conn, menuPath, map[string]map[string]*prop.Prop{
+		menuInterface: {
+			"Version":       {Value: uint32(3)},
+			"TextDirection": {Value: "ltr"},
+			"Status":        {Value: "normal"},
+			"IconThemePath": {Value: []string{}},
+		},
+	})
+	if err := t.conn.Export(menu, menuPath, menuInterface); err != nil {
+		return errors.Wrap(err, "export menu")
+	}
+	menuNode := &introspect.Node{
+		Name: menuPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       menuInterface,
+				Methods:    introspect.Methods(menu),
+				Properties: menuProps.Introspection(menuInterface),
+				Signals: []introspect.Signal{
+					{Name: "LayoutUpdated", Args: []introspect.Arg{
+						{Name: "revision", Type: "u"},
+						{Name: "parent", Type: "i"},
+					}},
+				},
+			},
+		},
+	}
+	if err := t.conn.Export(introspect.NewIntrospectable(menuNode), menuPath, introspectableIf); err != nil {
+		return errors.Wrap(err, "export menu introspection")
+	}
+
+	return nil
+}
+
+// register registers the item with the StatusNotifierWatcher, which tells the hosts drawing
+// the tray about it.
+func (t *sniTray) register() error {
+	call := t.conn.Object(watcherName, watcherPath).Call(watcherName+".RegisterStatusNotifierItem", 0, t.name)
+	if call.Err != nil {
+		return errors.Wrap(call.Err, "register status notifier item")
+	}
+	return nil
+}
+
+// watch registers the item again when the watcher restarts, eg. when the panel crashes.
+func (t *sniTray) watch() {
+	rule := fmt.Sprintf("type='signal',interface='org.freedesktop.DBus',member='NameOwnerChanged',arg0='%s'", watcherName)
+	if call := t.conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, rule); call.Err != nil {
+		logging.Warn("watch status notifier watcher", "err", call.Err)
+		return
+	}
+
+	signals := make(chan *dbus.Signal, 10)
+	t.conn.Signal(signals)
+	for signal := range signals {
+		if signal.Name != "org.freedesktop.DBus.NameOwnerChanged" || len(signal.Body) != 3 {
+			continue
+		}
+		if owner, _ := signal.Body[2].(string); owner != "" {
+			if err := t.register(); err != nil {
+				logging.Warn("register tray icon again", "err", err)
+			}
+		}
+	}
+}
+
+func (t *sniTray) SetMenu(items []Item) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	changed := !SameLayout(t.menu, items)
+
+	t.callbacks = make(map[int32]func())
+	next := int32(1)
+	t.layout = menuLayout{
+		ID:         0,
+		Properties: rootProperties(),
+		Children:   t.build(items, &next),
+	}
+	t.menu = items
+
+	if changed {
+		t.revision++
+		if err := t.conn.Emit(menuPath, menuInterface+".LayoutUpdated", t.revision, int32(0)); err != nil {
+			logging.Warn("emit layout updated", "err", err)
+		}
+	}
+}
+
+// build returns the layouts of the items. Ids are assigned in order, so the ids of an
+// unchanged menu stay the same.
+func (t *sniTray) build(items []Item, next *int32) []dbus.Variant {
+	children := []dbus.Variant{}
+	for _, item := range items {
+		layout := menuLayout{
+			ID:         *next,
+			Properties: make(map[string]dbus.Variant),
+		}
+		*next++
+
+		if item.Label == "" {
+			layout.Properties["type"] = dbus.MakeVariant("separator")
+		} else {
+
+			// Underscores mark the access key, escape them.
+			layout.Properties["label"] = dbus.MakeVariant(strings.Replace(item.Label, "_", "__", -1))
+			layout.Properties["enabled"] = dbus.MakeVariant(!item.Disabled)
+		}
+
+		if item.Items != nil {
+			layout.Properties["children-display"] = dbus.MakeVariant("submenu")
+			layout.Children = t.build(item.Items, next)
+		} else if item.Activate != nil {
+			t.callbacks[layout.ID] = item.Activate
+		}
+
+		children = append(children, dbus.MakeVariant(layout))
+	}
+	return children
+}
+
+func rootProperties() map[string]dbus.Variant {
+	return map[string]dbus.Variant{
+		"children-display": dbus.MakeVariant("submenu"),
+	}
+}
+
+// Loop calls the callbacks of the items activated since the last call.
+func (t *sniTray) Loop() {
+	for {
+		select {
+		case f := <-t.events:
+			f()
+		default:
+			return
+		}
+	}
+}
+
+func (t *sniTray) Close() error {
+	return t.conn.Close()
+}
+
+// queue runs f in Loop. Events are dropped if Loop isn't keeping up.
+func (t *sniTray) queue(f func()) {
+	if f == nil {
+		return
+	}
+	select {
+	case t.events <- f:
+	default:
+	}
+}
+
+// find returns the layout of the item with the id.
+func find(layout menuLayout, id int32) (menuLayout, bool) {
+	if layout.ID == id {
+		return layout, true
+	}
+	for _, child := range layout.Children {
+		if found, ok := find(child.Value().(menuLayout), id); ok {
+			return found, true
+		}
+	}
+	return menuLayout{}, false
+}
+
+// trim returns the layout without the children deeper than depth, all children if depth is
+// negative.
+func trim(layout menuLayout, depth int32) menuLayout {
+	if depth == 0 {
+		layout.Children = []dbus.Variant{}
+		return layout
+	}
+
+	children := make([]dbus.Variant, len(layout.Children))
+	for i, child := range layout.Children {
+		children[i] = dbus.MakeVariant(trim(child.Value().(menuLayout), depth-1))
+	}
+	layout.Children = children
+
+	return layout
+}
+
+// properties returns the properties of the layout and of all its children.
+func properties(layout menuLayout) []menuProperties {
+	props := []menuProperties{{ID: layout.ID, Properties: layout.Properties}}
+	for _, child := range layout.Children {
+		props = append(props, properties(child.Value().(menuLayout))...)
+	}
+	return props
+}
+
+// sniItem implements org.kde.StatusNotifierItem.
+type sniItem struct {
+	t *sniTray
+}
+
+func (i sniItem) Activate(x, y int32) *dbus.Error {
+	i.t.queue(i.t.activate)
+	return nil
+}
+
+func (i sniItem) SecondaryActivate(x, y int32) *dbus.Error {
+	i.t.queue(i.t.activate)
+	return nil
+}
+
+// ContextMenu is only called if there is no menu.
+func (i sniItem) ContextMenu(x, y int32) *dbus.Error { return nil }
+
+func (i sniItem) Scroll(delta int32, orientation string) *dbus.Error { return nil }
+
+// dbusMenu implements com.canonical.dbusmenu.
+type dbusMenu struct {
+	t *sniTray
+}
+
+func (m dbusMenu) GetLayout(parentID, recursionDepth int32, propertyNames []string) (uint32, menuLayout, *dbus.Error) {
+	m.t.mu.Lock()
+	defer m.t.mu.Unlock()
+
+	layout, ok := find(m.t.layout, parentID)
+	if !ok {
+		return 0, menuLayout{}, dbus.NewError(menuErrorName, []interface{}{fmt.Sprintf("unknown id %d", parentID)})
+	}
+
+	return m.t.revision, trim(layout, recursionDepth), nil
+}
+
+// GetGroupProperties returns the properties of the items, all items if ids is empty. All
+// properties are returned, hosts ignore the ones they didn't ask for.
+func (m dbusMenu) GetGroupProperties(ids []int32, propertyNames []string) ([]menuProperties, *dbus.Error) {
+	m.t.mu.Lock()
+	defer m.t.mu.Unlock()
+
+	all := properties(m.t.layout)
+	if len(ids) == 0 {
+		return all, nil
+	}
+
+	var props []menuProperties
+	for _, p := range all {
+		for _, id := range ids {
+			if p.ID == id {
+				props = append(props, p)
+			}
+		}
+	}
+	return props, nil
+}
+
+func (m dbusMenu) GetProperty(id int32, name string) (dbus.Variant, *dbus.Error) {
+	m.t.mu.Lock()
+	defer m.t.mu.Unlock()
+
+	layout, ok := find(m.t.layout, id)
+	if !ok {
+		return dbus.Variant{}, dbus.NewError(menuErrorName, []interface{}{fmt.Sprintf("unknown id %d", id)})
+	}
+	value, ok := layout.Properties[name]
+	if !ok {
+		return dbus.Variant{}, dbus.NewError(menuErrorName, []interface{}{fmt.Sprintf("unknown property %s", name)})
+	}
+	return value, nil
+}
+
+// Event runs the callback of a clicked item.
+func (m dbusMenu) Event(id int32, eventID string, data dbus.Variant, timestamp uint32) *dbus.Error {
+	if eventID != "clicked" {
+		return nil
+	}
+
+	m.t.mu.Lock()
+	f, ok := m.t.callbacks[id]
+	m.t.mu.Unlock()
+
+	if !ok {
+		return dbus.NewError(menuErrorName, []interface{}{fmt.Sprintf("unknown id %d", id)})
+	}
+	m.t.queue(f)
+
+	return nil
+}
+
+// EventGroup handles several events and returns the ids of the unknown items.
+func (m dbusMenu) EventGroup(events []menuEvent) ([]int32, *dbus.Error) {
+	idErrors := []int32{}
+	for _, e := range events {
+		if err := m.Event(e.ID, e.EventID, e.Data, e.Timestamp); err != nil {
+			idErrors = append(idErrors, e.ID)
+		}
+	}
+	return idErrors, nil
+}
+
+// AboutToShow reports whether the menu needs an update before it is shown. The menu is
+// always up to date.
+func (m dbusMenu) AboutToShow(id int32) (bool, *dbus.Error) {
+	return false, nil
+}
+
+func (m dbusMenu) AboutToShowGroup(ids []int32) ([]int32, []int32, *dbus.Error) {
+	return []int32{}, []int32{}, nil
+}
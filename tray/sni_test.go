@@ -0,0 +1,189 @@
+package tray
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus"
+	"github.com/michalnicp/1pass/dbustest"
+	"github.com/pkg/errors"
+)
+
+// watcher is a StatusNotifierWatcher accepting any item.
+type watcher struct {
+	items chan string
+}
+
+func (w watcher) RegisterStatusNotifierItem(service string) *dbus.Error {
+	w.items <- service
+	return nil
+}
+
+// newTestSNI starts a private session bus with a watcher and an item and returns a
+// connection to call the item with. The returned function stops the bus.
+func newTestSNI(t *testing.T, activate func()) (*sniTray, *dbus.Conn, func()) {
+	bus, err := dbustest.Start()
+	if errors.Cause(err) == dbustest.ErrNotInstalled {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := bus.Conn()
+	if err != nil {
+		bus.Close()
+		t.Fatal(err)
+	}
+	done := func() {
+		conn.Close()
+		bus.Close()
+	}
+
+	w := watcher{items: make(chan string, 1)}
+	if err := conn.Export(w, watcherPath, watcherName); err != nil {
+		done()
+		t.Fatal(err)
+	}
+	if reply, err := conn.RequestName(watcherName, dbus.NameFlagDoNotQueue); err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		done()
+		t.Fatalf("request watcher name: %v", err)
+	}
+
+	tr, err := newSNI(activate)
+	if err != nil {
+		done()
+		t.Fatal(err)
+	}
+
+	select {
+	case name := <-w.items:
+		if name != tr.(*sniTray).name {
+			t.Fatalf("registered %s, want %s", name, tr.(*sniTray).name)
+		}
+	case <-time.After(time.Second):
+		tr.Close()
+		done()
+		t.Fatal("item not registered")
+	}
+
+	return tr.(*sniTray), conn, func() {
+		tr.Close()
+		done()
+	}
+}
+
+// format returns the labels of the layout returned over the bus, submenus in brackets and
+// separators as -. Disabled items are marked with !.
+func format(layout []interface{}) string {
+	var items []string
+	for _, child := range layout[2].([]dbus.Variant) {
+		fields := child.Value().([]interface{})
+		props := fields[1].(map[string]dbus.Variant)
+
+		s := "-"
+		if label, ok := props["label"]; ok {
+			s = label.Value().(string)
+			if enabled, ok := props["enabled"]; ok && !enabled.Value().(bool) {
+				s += "!"
+			}
+		}
+		if display, ok := props["children-display"]; ok && display.Value() == "submenu" {
+			s += "[" + format(fields) + "]"
+		}
+		items = append(items, s)
+	}
+	return strings.Join(items, ",")
+}
+
+func TestSNILayout(t *testing.T) {
+	tr, conn, done := newTestSNI(t, func() {})
+	defer done()
+
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+	rule := fmt.Sprintf("type='signal',interface='%s',member='LayoutUpdated'", menuInterface)
+	if call := conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, rule); call.Err != nil {
+		t.Fatal(call.Err)
+	}
+
+	var clicked []string
+	menu := []Item{
+		{Label: "Show", Activate: func() { clicked = append(clicked, "Show") }},
+		{},
+		{Label: "Recent", Items: []Item{
+			{Label: "example_com", Items: []Item{
+				{Label: "Copy Password", Activate: func() { clicked = append(clicked, "Copy Password") }},
+			}},
+		}},
+		{Label: "Accounts", Disabled: true, Items: []Item{}},
+		{Label: "Quit", Activate: func() { clicked = append(clicked, "Quit") }},
+	}
+	tr.SetMenu(menu)
+
+	revision := waitLayoutUpdated(t, signals)
+
+	obj := conn.Object(tr.name, menuPath)
+
+	var gotRevision uint32
+	var layout []interface{}
+	if err := obj.Call(menuInterface+".GetLayout", 0, int32(0), int32(-1), []string{}).Store(&gotRevision, &layout); err != nil {
+		t.Fatal(err)
+	}
+	if gotRevision != revision {
+		t.Errorf("got revision %d, want %d", gotRevision, revision)
+	}
+	want := "Show,-,Recent[example__com[Copy Password]],Accounts![],Quit"
+	if got := format(layout); got != want {
+		t.Fatalf("got layout %s, want %s", got, want)
+	}
+
+	// Only the direct children of a submenu.
+	if err := obj.Call(menuInterface+".GetLayout", 0, int32(3), int32(1), []string{}).Store(&gotRevision, &layout); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := format(layout), "example__com[]"; got != want {
+		t.Fatalf("got submenu %s, want %s", got, want)
+	}
+
+	// Ids are assigned depth first: Show 1, separator 2, Recent 3, example_com 4, Copy
+	// Password 5.
+	for _, id := range []int32{5, 1} {
+		if call := obj.Call(menuInterface+".Event", 0, id, "clicked", dbus.MakeVariant(""), uint32(0)); call.Err != nil {
+			t.Fatal(call.Err)
+		}
+	}
+	if call := obj.Call(menuInterface+".Event", 0, int32(100), "clicked", dbus.MakeVariant(""), uint32(0)); call.Err == nil {
+		t.Error("expected an error clicking an unknown item")
+	}
+	tr.Loop()
+	if got, want := strings.Join(clicked, ","), "Copy Password,Show"; got != want {
+		t.Errorf("got clicked %s, want %s", got, want)
+	}
+
+	// An unchanged layout keeps the revision, a changed one announces a new one.
+	tr.SetMenu(menu)
+	changed := append([]Item{}, menu...)
+	changed[0].Label = "Hide"
+	tr.SetMenu(changed)
+	if got := waitLayoutUpdated(t, signals); got != revision+1 {
+		t.Errorf("got revision %d, want %d", got, revision+1)
+	}
+}
+
+// waitLayoutUpdated returns the revision of the next LayoutUpdated signal.
+func waitLayoutUpdated(t *testing.T, signals chan *dbus.Signal) uint32 {
+	for {
+		select {
+		case signal := <-signals:
+			if signal.Name == menuInterface+".LayoutUpdated" {
+				return signal.Body[0].(uint32)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no LayoutUpdated signal")
+		}
+	}
+}
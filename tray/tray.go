@@ -1,30 +1,69 @@
+// Package tray shows an icon with a menu in the system tray, either with a GtkStatusIcon or
+// as a StatusNotifierItem over D-Bus.
 package tray
 
-/*
-#cgo CFLAGS: -Wno-deprecated-declarations
-#cgo pkg-config: gtk+-3.0
+import "github.com/pkg/errors"
 
-#include "tray.h"
-#include <gtk/gtk.h>
+// Backends.
+const (
+	Auto = "auto" // sni if a StatusNotifierItem host is running, gtk otherwise
+	GTK  = "gtk"
+	SNI  = "sni"
+)
 
-*/
-import "C"
+// Tray is an icon with a menu in the system tray.
+type Tray interface {
 
-var (
-	Activate func()
-	Quit     func()
-)
+	// SetMenu replaces the menu. Hosts may rebuild the menu while it is open, so it should
+	// only be called when SameLayout reports a change.
+	SetMenu(items []Item)
+
+	// Loop handles the events of the tray without blocking and calls the callbacks of the
+	// activated items. It must be called from the thread calling SetMenu.
+	Loop()
 
-//export activate
-func activate(widget *C.GtkWidget, data C.gpointer) {
-	Activate()
+	// Close removes the icon.
+	Close() error
 }
 
-//export quit
-func quit(widget *C.GtkWidget, data C.gpointer) {
-	Quit()
+// Item is an entry of the tray menu. An item without a label is a separator, an item with
+// Items opens a submenu.
+type Item struct {
+	Label    string
+	Disabled bool
+	Items    []Item
+	Activate func()
 }
 
-func Init() { C.init() }
+// New shows the icon using the backend. activate is called when the icon is clicked.
+func New(backend string, activate func()) (Tray, error) {
+	switch backend {
+	case Auto:
+		if t, err := newSNI(activate); err == nil {
+			return t, nil
+		}
+		return newGTK(activate)
+	case GTK:
+		return newGTK(activate)
+	case SNI:
+		return newSNI(activate)
+	}
+
+	return nil, errors.Errorf("unknown tray backend %q", backend)
+}
 
-func Loop() { C.loop() }
+// SameLayout reports whether the menus have the same labels and structure.
+func SameLayout(a, b []Item) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Label != b[i].Label ||
+			a[i].Disabled != b[i].Disabled ||
+			(a[i].Items == nil) != (b[i].Items == nil) ||
+			!SameLayout(a[i].Items, b[i].Items) {
+			return false
+		}
+	}
+	return true
+}
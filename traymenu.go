@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/michalnicp/1pass/logging"
+	"github.com/michalnicp/1pass/op"
+	"github.com/michalnicp/1pass/securemem"
+	"github.com/michalnicp/1pass/tray"
+)
+
+// recentSize is the number of recently used items in the tray menu.
+const recentSize = 5
+
+// trayMenu returns the tray menu for the current state. It is rebuilt every frame but only
+// passed to the tray when the layout changed, so the callbacks read the state when activated
+// instead of capturing it.
+func (s *UIState) trayMenu(window *glfw.Window) []tray.Item {
+	session := s.session
+	valid := session.Valid()
+	locked := session.Locked()
+
+	show := "Show"
+	if window.GetAttrib(glfw.Visible) == glfw.True {
+		show = "Hide"
+	}
+
+	recent := []tray.Item{}
+	for i, item := range s.recent {
+		i := i
+		recent = append(recent, tray.Item{
+			Label: item.Overview.Title,
+			Items: []tray.Item{
+				{Label: "Copy Username", Activate: func() { s.copyRecent(i, "username") }},
+				{Label: "Copy Password", Activate: func() { s.copyRecent(i, "password") }},
+			},
+		})
+	}
+
+	accounts := []tray.Item{}
+	for i, account := range s.accounts {
+		i := i
+		current := session != nil &&
+			accountKey(session.SigninAddress, session.Email) == accountKey(account.URL, account.Email)
+		accounts = append(accounts, tray.Item{
+			Label:    fmt.Sprintf("%s (%s)", account.Email, strings.TrimPrefix(account.URL, "https://")),
+			Disabled: current,
+			Activate: func() {
+				if i < len(s.accounts) {
+					s.switchAccount(s.accounts[i])
+					window.Show()
+				}
+			},
+		})
+	}
+
+	return []tray.Item{
+		{Label: show, Activate: func() { toggleWindow(window) }},
+		{},
+		{Label: "Recent", Disabled: !valid || len(recent) == 0, Items: recent},
+		{Label: "Accounts", Disabled: len(accounts) == 0, Items: accounts},
+		{},
+		{Label: "Lock", Disabled: !valid, Activate: s.lock},
+		{Label: "Sign Out", Disabled: session == nil, Activate: s.signOut},
+		{},
+		{Label: "Settings", Disabled: locked, Activate: func() {
+			s.showSettings()
+			window.Show()
+		}},
+		{Label: "Audit Log", Activate: func() {
+			s.showAuditLog()
+			window.Show()
+		}},
+		{},
+		{Label: "Quit", Activate: func() { window.SetShouldClose(true) }},
+	}
+}
+
+// addRecent moves the item to the front of the recently used items. Only the overview is
+// kept.
+func (s *UIState) addRecent(item op.Item) {
+	item.Details = nil
+
+	recent := []op.Item{item}
+	for _, r := range s.recent {
+		if r.UUID != item.UUID && len(recent) < recentSize {
+			recent = append(recent, r)
+		}
+	}
+	s.recent = recent
+}
+
+// copyRecent copies the username or password of the ith recently used item.
+func (s *UIState) copyRecent(i int, field string) {
+	if i < len(s.recent) {
+		s.copyField(s.recent[i], field)
+	}
+}
+
+// copyField copies the username or password of the item to the clipboard.
+func (s *UIState) copyField(item op.Item, field string) {
+	clipboard := s.config.Clipboard
+	session := s.session
+
+	go func() {
+		details, err := session.GetItem(item.UUID)
+		if err != nil {
+			logging.Error("get item", "item", item.UUID, "err", err)
+			return
+		}
+		if details.Details == nil {
+			return
+		}
+
+		secret := details.Details.Value(field)
+		value, err := securemem.New(len(secret))
+		if err != nil {
+			logging.Error("copy "+field, "item", item.UUID, "err", err)
+			return
+		}
+		defer value.Destroy()
+		copy(value.Bytes(), secret)
+
+		if err := writeClipboard(clipboard, value.Bytes()); err != nil {
+			logging.Error("copy "+field, "item", item.UUID, "err", err)
+			return
+		}
+		recordAccess(logging.ActionCopy, &item, field, "tray")
+
+		s.queue(func() {
+			if s.session == session {
+				s.addRecent(item)
+			}
+		})
+	}()
+}
+
+// signOut signs out of the account and shows the sign in view.
+func (s *UIState) signOut() {
+	signedIn := s.session
+	if signedIn == nil {
+		return
+	}
+
+	s.sessions.Remove(signedIn)
+	go func() {
+		if err := signedIn.Signout(); err != nil {
+			logging.Error("sign out", "err", err)
+		}
+	}()
+
+	// Show the sign in view now rather than when the change is processed.
+	s.sessionChanged()
+	s.settings = nil
+	s.isShowingAuditLog = false
+}
+
+// switchAccount shows the session of the account if signed in to it. Otherwise the sign in
+// view is filled in with the account, the session of the previous account is kept.
+func (s *UIState) switchAccount(account op.Account) {
+	s.settings = nil
+	s.isShowingAuditLog = false
+
+	if !s.sessions.Switch(account.URL, account.Email) {
+		s.sessions.Set(nil)
+	}
+
+	// Show the account now rather than when the change is processed.
+	s.sessionChanged()
+
+	if s.session != nil {
+		return
+	}
+
+	s.signinAddress = []byte(account.URL)
+	s.signinAddressLen = int32(len(account.URL))
+	s.emailLen = int32(copy(s.email, account.Email))
+	s.secretKeyLen = int32(copy(s.secretKey, account.AccountKey))
+}
+
+// loadAccounts reads the accounts op knows about.
+func (s *UIState) loadAccounts() {
+	cfg, err := op.ReadConfig()
+	if err != nil {
+		logging.Debug("read op config", "err", err)
+		return
+	}
+	s.accounts = cfg.Accounts
+}
@@ -4,24 +4,39 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"log"
 	"sync"
+	"time"
 
 	"github.com/go-gl/glfw/v3.2/glfw"
 	"github.com/golang-ui/nuklear/nk"
+	"github.com/michalnicp/1pass/audit"
+	"github.com/michalnicp/1pass/config"
+	"github.com/michalnicp/1pass/logging"
 	"github.com/michalnicp/1pass/op"
+	"github.com/michalnicp/1pass/securemem"
+	"github.com/pkg/errors"
 )
 
 const (
-	signinText       = "Sign in to your 1Password account"
-	bufSize    int32 = 256 * 1024
+	signinText          = "Sign in to your 1Password account"
+	bufSize       int32 = 256 * 1024
+	secretBufSize int32 = 4 * 1024
 )
 
 var font *nk.Font
 
 type UIState struct {
+	config    *config.Config
 	queueChan chan func()
 
+	// Session. The session shown is only changed on the main thread, goroutines get the
+	// current session from the store.
+	sessions *sessionStore
+	session  *op.Session
+
+	// Keys that were down in the previous frame.
+	keys map[glfw.Key]bool
+
 	// Tab.
 	id       int32
 	activeID int32
@@ -36,6 +51,9 @@ type UIState struct {
 	secretKeyLen      int32
 	masterPassword    []byte
 	masterPasswordLen int32
+	pin               []byte
+	pinLen            int32
+	mask              []byte // shown instead of passwords
 	isSigningIn       bool
 
 	// Search.
@@ -43,38 +61,119 @@ type UIState struct {
 	searchCancel    context.CancelFunc
 	searchQuery     []byte
 	searchQueryLen  int32
+	searchQuerySet  bool // the query was changed outside of the search field
 	items           []op.Item
 	searchResults   []op.Item
 	selectedItem    *op.Item
 	isFetchingItems bool
 	isFetchingItem  bool
 
+	// Audit.
+	showAudit    bool
+	isAuditing   bool
+	auditOptions audit.Options
+	auditReport  *audit.Report
+
+	// Audit log.
+	isShowingAuditLog bool
+	auditEvents       []logging.AuditEvent
+
+	// Tray.
+	recent   []op.Item // recently used items, most recent first
+	accounts []op.Account
+
+	// Settings, the config file as read without the flags, and a copy being edited or nil.
+	configPath string
+	fileConfig *config.Config
+	settings   *config.Config
+
+	// Confirm.
+	confirms []*confirmRequest
+
+	// Lock.
+	lastActivity time.Time
+	isUnlocking  bool
+
 	// Status.
 	statusText string
 }
 
-func NewUIState() *UIState {
+func NewUIState(cfg *config.Config, sessions *sessionStore) (*UIState, error) {
+
+	// Secrets typed in the ui are kept in secure memory.
+	secrets := make([][]byte, 4)
+	for i := range secrets {
+		buf, err := securemem.New(int(secretBufSize))
+		if err != nil {
+			return nil, errors.Wrap(err, "allocate secure memory")
+		}
+		if err := buf.LockError(); err != nil && i == 0 {
+			logging.Warn("lock secure memory, secrets may be swapped to disk", "err", err)
+		}
+		secrets[i] = buf.Bytes()
+	}
+
 	state := UIState{
+		config:    cfg,
 		queueChan: make(chan func(), 10),
+		sessions:  sessions,
+		session:   sessions.Get(),
+		keys:      make(map[glfw.Key]bool),
 		id:        -1,
 		activeID:  -1,
 
+		auditOptions: audit.DefaultOptions,
+		lastActivity: time.Now(),
+
 		email:          make([]byte, bufSize),
-		secretKey:      make([]byte, bufSize),
-		masterPassword: make([]byte, bufSize),
+		secretKey:      secrets[0],
+		masterPassword: secrets[1],
+		pin:            secrets[2],
+		mask:           secrets[3],
 		searchQuery:    make([]byte, bufSize),
 	}
 
-	if session != nil {
+	if session := state.session; session != nil {
 		state.signinAddress = []byte(session.SigninAddress)
 		state.signinAddressLen = int32(len(session.SigninAddress))
-		state.email = []byte(session.Email)
-		state.emailLen = int32(len(session.Email))
-		state.secretKey = []byte(session.SecretKey)
-		state.secretKeyLen = int32(len(session.SecretKey))
+		state.emailLen = int32(copy(state.email, session.Email))
+		state.secretKeyLen = int32(copy(state.secretKey, session.SecretKey))
 	}
 
-	return &state
+	state.loadAccounts()
+
+	// The session may be changed on the main thread, where queueing could block.
+	sessions.Watch(func(*op.Session) {
+		go func() {
+			state.queue(state.sessionChanged)
+			glfw.PostEmptyEvent()
+		}()
+	})
+
+	return &state, nil
+}
+
+// setSearchQuery replaces the search query and shows the search view.
+func (s *UIState) setSearchQuery(query string) {
+	s.searchQueryLen = int32(copy(s.searchQuery, query))
+	s.searchQuerySet = true
+	s.showAudit = false
+	s.isShowingAuditLog = false
+	s.settings = nil
+}
+
+// takeSecret returns a copy of the secret typed in the ui in secure memory and clears the
+// field. The copy must be destroyed after use.
+func takeSecret(buf []byte, length *int32) (*securemem.Buffer, error) {
+	secret, err := securemem.Copy(buf[:*length])
+	if err != nil {
+		return nil, errors.Wrap(err, "copy secret")
+	}
+
+	securemem.Wipe(buf)
+	*length = 0
+
+	return secret, nil
 }
 
 // tab executes the function if the widget is focused using tab.
@@ -85,6 +184,14 @@ func (s *UIState) tab(f func()) {
 	}
 }
 
+// pressed reports whether the key went down since the previous frame.
+func (s *UIState) pressed(window *glfw.Window, key glfw.Key) bool {
+	down := window.GetKey(key) == glfw.Press
+	wasDown := s.keys[key]
+	s.keys[key] = down
+	return down && !wasDown
+}
+
 // queue adds a function to the queue.
 func (s *UIState) queue(f func()) {
 	s.queueChan <- f
@@ -102,11 +209,40 @@ func (s *UIState) processQueue() {
 	}
 }
 
+// sessionChanged shows the current session of the store. Changes may be queued out of
+// order, so the session is read from the store rather than passed in.
+func (s *UIState) sessionChanged() {
+	session := s.sessions.Get()
+	if session == s.session {
+		return
+	}
+	s.session = session
+
+	// Recent items belong to the previous account.
+	s.recent = nil
+
+	if session == nil {
+		s.forget()
+	} else {
+		// Load the items of the new session, keeping a query set before signing in.
+		if s.searchCancel != nil {
+			s.searchCancel()
+		}
+		s.items = nil
+		s.searchOnce = sync.Once{}
+		s.searchResults = nil
+		s.selectedItem = nil
+		s.auditReport = nil
+	}
+
+	s.loadAccounts()
+}
+
 // UI creates a new frame and draws the ui.
 func UI(window *glfw.Window, ctx *nk.Context, state *UIState) {
 	defer func() {
 		if i := recover(); i != nil {
-			log.Printf("panic: %v", i)
+			logging.Error("panic", "value", i)
 		}
 	}()
 
@@ -123,16 +259,51 @@ func UI(window *glfw.Window, ctx *nk.Context, state *UIState) {
 	// Reset tab id.
 	state.id = -1
 
-	// Handle escape key.
-	if window.GetKey(glfw.KeyEscape) == glfw.Press {
-		window.Hide()
-		return
+	// Handle escape key. Leave the settings, the audit log, the audit or hide the window.
+	if state.pressed(window, glfw.KeyEscape) {
+		if state.settings != nil {
+			state.settings = nil
+		} else if state.isShowingAuditLog {
+			state.isShowingAuditLog = false
+			state.auditEvents = nil
+		} else if state.showAudit {
+			state.showAudit = false
+		} else {
+			window.Hide()
+			return
+		}
+	}
+
+	// Lock when idle.
+	session := state.session
+	if state.idle() && session.Valid() {
+		state.lock()
+	}
+
+	// Show the password audit with F2.
+	if state.pressed(window, glfw.KeyF2) && session.Valid() {
+		state.showAudit = true
+	}
+
+	// Show the settings with F3.
+	if state.pressed(window, glfw.KeyF3) && !session.Locked() {
+		state.showSettings()
 	}
 
 	// Create a new frame and draw to it.
 	nk.NkPlatformNewFrame()
 
-	if session.Valid() {
+	if len(state.confirms) > 0 {
+		Confirm(window, ctx, state)
+	} else if session.Locked() {
+		Lock(window, ctx, state)
+	} else if state.settings != nil {
+		Settings(window, ctx, state)
+	} else if state.isShowingAuditLog {
+		AuditLog(window, ctx, state)
+	} else if session.Valid() && state.showAudit {
+		Audit(window, ctx, state)
+	} else if session.Valid() {
 		Search(window, ctx, state)
 	} else {
 		Signin(window, ctx, state)
@@ -146,23 +317,65 @@ func Signin(window *glfw.Window, ctx *nk.Context, state *UIState) {
 
 		signinAddress := string(state.signinAddress[:state.signinAddressLen])
 		email := string(state.email[:state.emailLen])
-		secretKey := string(state.secretKey[:state.secretKeyLen])
-		masterPassword := string(state.masterPassword[:state.masterPasswordLen])
+		offline := state.config.Offline
+
+		// The secret key stays in the form, it is shown until the session is created.
+		secretKey, err := securemem.Copy(state.secretKey[:state.secretKeyLen])
+		if err != nil {
+			logging.Error("sign in", "err", err)
+			state.isSigningIn = false
+			return
+		}
+		masterPassword, err := takeSecret(state.masterPassword, &state.masterPasswordLen)
+		if err != nil {
+			logging.Error("sign in", "err", err)
+			secretKey.Destroy()
+			state.isSigningIn = false
+			return
+		}
+		pin, err := takeSecret(state.pin, &state.pinLen)
+		if err != nil {
+			logging.Error("sign in", "err", err)
+			secretKey.Destroy()
+			masterPassword.Destroy()
+			state.isSigningIn = false
+			return
+		}
 
 		go func() {
+			defer secretKey.Destroy()
+			defer masterPassword.Destroy()
+			defer pin.Destroy()
 			defer state.queue(func() {
 				state.isSigningIn = false
 			})
 
-			var err error
-			session, err = op.Signin(signinAddress, email, secretKey, masterPassword)
+			session, err := op.Signin(signinAddress, email, secretKey.Bytes(), masterPassword.Bytes())
+			if err != nil && offline && op.IsNetworkError(err) {
+				logging.Warn("sign in failed, using offline items", "err", err)
+				session, err = signinOffline(masterPassword.Bytes())
+			}
 			if err != nil {
-				log.Printf("signin: %v", err)
+				logging.Error("sign in", "err", err)
 				state.queue(func() {
 					state.statusText = fmt.Sprintf("signin: %v", err)
 				})
 				return
 			}
+
+			if offline && !session.Offline() {
+				if err := syncOffline(session, masterPassword.Bytes()); err != nil {
+					logging.Error("sync offline items", "err", err)
+				}
+			}
+
+			if len(pin.Bytes()) > 0 && !session.Offline() {
+				if err := session.SetPIN(pin.Bytes()); err != nil {
+					logging.Error("set pin", "err", err)
+				}
+			}
+
+			state.sessions.Set(session)
 		}()
 	}
 
@@ -199,38 +412,28 @@ func Signin(window *glfw.Window, ctx *nk.Context, state *UIState) {
 			nk.EditField,
 			state.secretKey,
 			&state.secretKeyLen,
-			bufSize,
+			secretBufSize,
 			nk.NkFilterDefault,
 		)
 
-		// Mask password with asterisks.
 		nk.NkLabel(ctx, "Master Password", nk.TextLeft)
-		oldLen := state.masterPasswordLen
-		buf := make([]byte, bufSize)
-		for i := 0; i < int(state.masterPasswordLen); i++ {
-			buf[i] = '*'
-		}
 		state.tab(func() {
 			nk.NkEditFocus(ctx, nk.EditField|nk.EditGotoEndOnActivate)
 		})
 		state.signinOnce.Do(func() {
-			if session != nil &&
+			if session := state.session; session != nil &&
 				session.Email != "" &&
-				session.Token == "" {
+				!session.HasToken() {
 				nk.NkEditFocus(ctx, nk.EditField|nk.EditGotoEndOnActivate)
 			}
 		})
-		nk.NkEditString(
-			ctx,
-			nk.EditField,
-			buf,
-			&state.masterPasswordLen,
-			bufSize,
-			nk.NkFilterDefault,
-		)
-		if oldLen < state.masterPasswordLen {
-			copy(state.masterPassword[oldLen:], buf[oldLen:state.masterPasswordLen])
-		}
+		PasswordEdit(ctx, state.mask, state.masterPassword, &state.masterPasswordLen)
+
+		nk.NkLabel(ctx, "PIN to unlock (optional)", nk.TextLeft)
+		state.tab(func() {
+			nk.NkEditFocus(ctx, nk.EditField|nk.EditGotoEndOnActivate)
+		})
+		PasswordEdit(ctx, state.mask, state.pin, &state.pinLen)
 
 		// Padding.
 		nk.NkLayoutRowStatic(ctx, 10, 0, 0)
@@ -248,6 +451,7 @@ func Signin(window *glfw.Window, ctx *nk.Context, state *UIState) {
 func Search(window *glfw.Window, ctx *nk.Context, state *UIState) {
 	state.searchOnce.Do(func() {
 		state.isFetchingItems = true
+		session := state.session
 		go func() {
 			defer state.queue(func() {
 				state.isFetchingItems = false
@@ -255,12 +459,16 @@ func Search(window *glfw.Window, ctx *nk.Context, state *UIState) {
 
 			items, err := session.ListItems()
 			if err != nil {
-				log.Printf("list items: %v", err)
+				logging.Error("list items", "err", err)
 				return
 			}
 
-			log.Printf("list items returned %d items", len(items))
+			logging.Debug("list items", "count", len(items))
 			state.queue(func() {
+				// The items of a session signed out of meanwhile are dropped.
+				if state.session != session {
+					return
+				}
 				state.items = items
 				state.searchResults = state.items[:]
 				state.statusText = fmt.Sprintf("%d results", len(items))
@@ -295,7 +503,8 @@ func Search(window *glfw.Window, ctx *nk.Context, state *UIState) {
 			nk.NkFilterDefault,
 		)
 
-		if !bytes.Equal(searchQuery, state.searchQuery[:state.searchQueryLen]) {
+		if state.searchQuerySet || !bytes.Equal(searchQuery, state.searchQuery[:state.searchQueryLen]) {
+			state.searchQuerySet = false
 			state.selectedItem = nil
 			if state.isFetchingItems {
 
@@ -312,6 +521,7 @@ func Search(window *glfw.Window, ctx *nk.Context, state *UIState) {
 				state.searchResults = state.items[:]
 				state.isFetchingItems = false
 			} else {
+				session := state.session
 				go func() {
 					defer state.queue(func() {
 						select {
@@ -323,7 +533,7 @@ func Search(window *glfw.Window, ctx *nk.Context, state *UIState) {
 
 					results, err := session.SearchItems(query)
 					if err != nil {
-						log.Printf("search items: %v", err)
+						logging.Error("search items", "err", err)
 						return
 					}
 
@@ -383,34 +593,31 @@ func searchResultItem(window *glfw.Window, ctx *nk.Context, state *UIState, item
 			if state.selectedItem.Details != nil {
 
 				// Display the username and password.
-				var username string
-				for _, field := range state.selectedItem.Details.Fields {
-					if field.Designation == "username" {
-						username = field.Value
-						break
-					}
-				}
-				var password string
-				for _, field := range state.selectedItem.Details.Fields {
-					if field.Designation == "password" {
-						password = field.Value
-						break
-					}
-				}
+				username := state.selectedItem.Details.Value("username")
+				password := state.selectedItem.Details.Value("password")
 
 				nk.NkLayoutRowDynamic(ctx, 40, 2)
 				if CopyButton(ctx, "username", username) > 0 {
-					if err := writeClipboard(username); err != nil {
-						log.Printf("copy username: %v", err)
+					if err := writeClipboard(state.config.Clipboard, []byte(username)); err != nil {
+						logging.Error("copy username", "item", item.UUID, "err", err)
 					} else {
-						log.Println("username copied")
+						recordAccess(logging.ActionCopy, state.selectedItem, "username", "ui")
+						state.addRecent(item)
 					}
 				}
 				if CopyButton(ctx, "password", "********") > 0 {
-					if err := writeClipboard(password); err != nil {
-						log.Printf("copy password: %v", err)
+					// Copied straight from the string, a []byte conversion leaves a copy on the heap.
+					secret, err := securemem.New(len(password))
+					if err == nil {
+						copy(secret.Bytes(), password)
+						err = writeClipboard(state.config.Clipboard, secret.Bytes())
+						secret.Destroy()
+					}
+					if err != nil {
+						logging.Error("copy password", "item", item.UUID, "err", err)
 					} else {
-						log.Println("password copied")
+						recordAccess(logging.ActionCopy, state.selectedItem, "password", "ui")
+						state.addRecent(item)
 					}
 				}
 			}
@@ -427,6 +634,7 @@ func searchResultItem(window *glfw.Window, ctx *nk.Context, state *UIState, item
 		state.isFetchingItem = true
 		state.selectedItem = &item
 
+		session := state.session
 		go func() {
 			defer state.queue(func() {
 				state.isFetchingItem = false
@@ -435,20 +643,50 @@ func searchResultItem(window *glfw.Window, ctx *nk.Context, state *UIState, item
 			// Get item details.
 			item, err := session.GetItem(item.UUID)
 			if err != nil {
-				log.Printf("get item: %v", err)
+				logging.Error("get item", "err", err)
 				return
 			}
 
 			state.queue(func() {
-				state.selectedItem = item
+				if state.session == session {
+					state.selectedItem = item
+				}
 			})
 		}()
 	}
 }
 
+// PasswordEdit draws an edit field for a password masked with asterisks. Typed characters
+// are written to mask, which is wiped after they are copied to the password.
+func PasswordEdit(ctx *nk.Context, mask, password []byte, length *int32) {
+	oldLen := *length
+	for i := 0; i < int(*length); i++ {
+		mask[i] = '*'
+	}
+	nk.NkEditString(
+		ctx,
+		nk.EditField,
+		mask,
+		length,
+		int32(len(mask)),
+		nk.NkFilterDefault,
+	)
+	if oldLen < *length {
+		copy(password[oldLen:], mask[oldLen:*length])
+	}
+	securemem.Wipe(mask)
+}
+
 // StatusLine draws the status line.
 func StatusLine(window *glfw.Window, ctx *nk.Context, state *UIState) {
-	nk.NkLabel(ctx, state.statusText, nk.TextLeft)
+	text := state.statusText
+	if session := state.session; session.Offline() {
+		text = fmt.Sprintf("offline, last synced at %s", session.SyncedAt().Format("2006-01-02 15:04"))
+		if state.statusText != "" {
+			text += ", " + state.statusText
+		}
+	}
+	nk.NkLabel(ctx, text, nk.TextLeft)
 }
 
 func CopyButton(ctx *nk.Context, text1, text2 string) int32 {
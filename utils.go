@@ -1,6 +1,9 @@
 package main
 
 import (
+	"os"
+	"os/user"
+	"path/filepath"
 	"time"
 )
 
@@ -17,3 +20,31 @@ func debounce(duration time.Duration, f func()) func() {
 		}
 	}
 }
+
+// dataDir returns the directory for persistent application data, $XDG_DATA_HOME/1pass.
+func dataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "1pass"), nil
+	}
+
+	user, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(user.HomeDir, ".local", "share", "1pass"), nil
+}
+
+// cacheDir returns the directory for non essential cached data, $XDG_CACHE_HOME/1pass.
+func cacheDir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "1pass"), nil
+	}
+
+	user, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(user.HomeDir, ".cache", "1pass"), nil
+}
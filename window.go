@@ -20,3 +20,31 @@ func toggleWindow(window *glfw.Window) {
 		window.Show()
 	}
 }
+
+// watchActivity calls f on keyboard and mouse input, keeping the callbacks installed by
+// nuklear.
+func watchActivity(window *glfw.Window, f func()) {
+	var keyCallback glfw.KeyCallback
+	keyCallback = window.SetKeyCallback(func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+		f()
+		if keyCallback != nil {
+			keyCallback(w, key, scancode, action, mods)
+		}
+	})
+
+	var mouseButtonCallback glfw.MouseButtonCallback
+	mouseButtonCallback = window.SetMouseButtonCallback(func(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
+		f()
+		if mouseButtonCallback != nil {
+			mouseButtonCallback(w, button, action, mods)
+		}
+	})
+
+	var cursorPosCallback glfw.CursorPosCallback
+	cursorPosCallback = window.SetCursorPosCallback(func(w *glfw.Window, x, y float64) {
+		f()
+		if cursorPosCallback != nil {
+			cursorPosCallback(w, x, y)
+		}
+	})
+}